@@ -2,24 +2,354 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/dddpaul/gonc/metrics"
+	"github.com/dddpaul/gonc/npipe"
+	"github.com/dddpaul/gonc/stdio"
 	"github.com/dddpaul/gonc/tcp"
+	"github.com/dddpaul/gonc/transport"
+	"github.com/dddpaul/gonc/tunnel"
 	"github.com/dddpaul/gonc/udp"
+	"github.com/dddpaul/gonc/vsock"
 )
 
 func main() {
-	var host, port, proto string
-	var listen bool
+	var host, port, proto, order, payloadFile, payloadHex, handshakeFile, handshakeHex, targetsFile, metricsAddr, listenAddress, deadline, pidFile string
+	var listen, resolveOnly, closeOnEOF, noDNS, selfTest, udpProbe bool
+	var connections int
 	flag.StringVar(&host, "host", "", "Remote host to connect, i.e. 127.0.0.1")
-	flag.StringVar(&proto, "proto", "tcp", "TCP/UDP mode")
+	flag.StringVar(&proto, "proto", "tcp", "TCP/UDP/NPIPE/VSOCK/AUTO mode, tcp4/tcp6/udp4/udp6 restrict IP version, named pipe path is taken from -port in NPIPE mode, i.e. \\\\.\\pipe\\gonc, CID:port is taken from -port in VSOCK mode, i.e. 3:1024")
+	flag.StringVar(&order, "auto-order", "tcp,udp", "Transports to try one by one in AUTO client mode")
+	flag.DurationVar(&tcp.Timeout, "timeout", 0, "TCP connect timeout (response timeout of -udp-probe), i.e. 5s, no timeout if zero")
 	flag.BoolVar(&listen, "listen", false, "Listen mode")
+	flag.IntVar(&tcp.Backlog, "backlog", 0, "TCP listen backlog, system default if zero")
+	flag.DurationVar(&transport.MaxDuration, "max-duration", 0, "Close connection once it has lasted for given duration regardless of activity, i.e. 1m")
 	flag.StringVar(&port, "port", ":9999", "Port to listen on or connect to (prepended by colon), i.e. :9999")
+	flag.IntVar(&udp.MaxDatagramSize, "max-datagram-size", 0, "Maximum size of UDP datagram being sent, larger input is rejected unless -split-datagrams is set")
+	flag.BoolVar(&udp.SplitDatagrams, "split-datagrams", false, "Split UDP input exceeding -max-datagram-size into several datagrams")
+	flag.BoolVar(&udp.LockPeer, "udp-lock-peer", false, "Drop datagrams from anyone except the first peer in UDP listen mode")
+	flag.BoolVar(&udp.ForwardEmpty, "udp-forward-empty", false, "Forward zero-length UDP datagrams instead of skipping them")
+	flag.DurationVar(&tcp.NagleOffAfter, "nagle-off-after", 0, "Flush first write of every burst at once and coalesce the rest by Nagle, bursts are separated by this idle period, i.e. 200ms")
+	flag.BoolVar(&tcp.DrainStdin, "drain-stdin", false, "Deprecated, does nothing: standard input is always stopped once TCP connection has been closed by remote peer")
+	flag.StringVar(&stdio.Mode, "mode", "stdio", "Local side mode: stdio (standard input and output), reflect (send received data back) or sink (discard received data and log throughput)")
+	flag.StringVar(&stdio.ReflectTransform, "reflect-transform", "none", "Transformation of reflected data: none, upper, lower, reverse or rot13")
+	flag.BoolVar(&stdio.JSONLines, "json-lines", false, "Print received data as JSON lines with base64 encoded data_b64 field, read data to be sent from such lines")
+	flag.StringVar(&stdio.Exec, "exec", "", "Command to execute, its input and output are connected to remote peer, i.e. \"/bin/sh -i\"")
+	flag.BoolVar(&stdio.PTY, "pty", false, "Run -exec command in pseudo-terminal (Unix only)")
+	flag.BoolVar(&stdio.RawStdin, "raw-stdin", false, "Switch terminal attached to standard input into raw mode for the duration of session")
+	flag.StringVar(&tunnel.Server, "ssh", "", "SSH server to connect through in TCP client mode, i.e. user@bastion:22")
+	flag.StringVar(&tunnel.Key, "ssh-key", "", "Private key file for SSH authentication, SSH agent is used as well")
+	flag.StringVar(&tunnel.KnownHosts, "ssh-known-hosts", tunnel.KnownHosts, "Known hosts file to verify SSH server host key")
+	flag.BoolVar(&tunnel.Insecure, "ssh-insecure", false, "Don't verify SSH server host key")
+	flag.BoolVar(&resolveOnly, "resolve-only", false, "Print addresses -host resolves to and exit")
+	flag.StringVar(&payloadFile, "payload-file", "", "File to send as the first datagram instead of standard input in UDP client mode")
+	flag.StringVar(&payloadHex, "payload-hex", "", "Hex encoded payload to send as the first datagram instead of standard input in UDP client mode")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "Address to serve Prometheus metrics on, i.e. :9100")
+	flag.BoolVar(&transport.Timing, "timing", false, "Log connection establishment, first received byte and total transfer times")
+	flag.StringVar(&stdio.Tee, "tee", "", "File to archive received data to besides printing it")
+	flag.StringVar(&stdio.TeeSend, "tee-send", "", "File to archive sent data to")
+	flag.StringVar(&transport.Interface, "interface", "", "Zone of IPv6 link-local -host given without one, i.e. eth0")
+	flag.IntVar(&stdio.OutputBuffer, "output-buffer", 0, "Size of standard output buffer flushed every 100ms and on close, unbuffered if zero, i.e. 65536")
+	flag.IntVar(&connections, "connections", 1, "Number of parallel connections in TCP client mode, every one sends the whole standard input")
+	flag.BoolVar(&stdio.HexSend, "hex-send", false, "Decode data to be sent from hex lines, whitespace is ignored and lines starting with # are skipped")
+	flag.BoolVar(&stdio.HexSkipInvalid, "hex-skip-invalid", false, "Log and skip invalid -hex-send lines instead of stopping input with error")
+	flag.StringVar(&stdio.Format, "format", "raw", "Rendering of received data: raw, hex, hexdump, base64 or escaped (Go-style quoted)")
+	flag.BoolVar(&stdio.FormatBoth, "format-both", false, "Print sent data rendered by -format as well, chunks are prefixed by direction")
+	flag.StringVar(&transport.OnConnect, "on-connect", "", "Shell command to run in background once connection has been opened, GONC_REMOTE_ADDR and GONC_CONN_ID are set")
+	flag.StringVar(&transport.OnClose, "on-close", "", "Shell command to run in background once connection has been closed, GONC_BYTES_RECEIVED and GONC_BYTES_SENT are set as well")
+	flag.BoolVar(&transport.AdaptiveBuffer, "adaptive-buffer", false, "Start TCP transfer with small buffer which grows with observed read sizes")
+	flag.StringVar(&listenAddress, "listen-address", "", "Local address to listen on instead of all interfaces, i.e. 127.0.0.1")
+	flag.BoolVar(&closeOnEOF, "close-on-eof", false, "Close connection once standard input is over in listen mode too, client mode always does it")
+	flag.DurationVar(&udp.ProbeInterval, "probe-interval", 0, "Send keepalive datagram once nothing has been sent for given period in UDP mode, i.e. 25s")
+	flag.StringVar(&handshakeFile, "handshake-file", "", "File to send to accepted connection first, before standard input, in TCP listen mode")
+	flag.StringVar(&handshakeHex, "handshake-hex", "", "Hex encoded data to send to accepted connection first, before standard input, in TCP listen mode")
+	flag.StringVar(&udp.ProbePayload, "probe-payload", "", "Payload of keepalive datagram, empty by default")
+	flag.BoolVar(&tcp.TLS, "tls", false, "Use TLS over TCP, -tls-cert and -tls-key are required in listen mode")
+	flag.StringVar(&tcp.TLSVerifyName, "tls-verify-name", "", "Name to verify server certificate against (and to send as SNI) instead of -host")
+	flag.BoolVar(&tcp.TLSInsecure, "tls-insecure", false, "Don't verify server certificate")
+	flag.StringVar(&tcp.TLSCert, "tls-cert", "", "Certificate file (PEM) to present in TLS listen mode")
+	flag.StringVar(&tcp.TLSKey, "tls-key", "", "Private key file (PEM) of -tls-cert")
+	flag.Var(&stdio.SendFiles, "send-file", "Files to send one by one instead of standard input, comma separated or repeated flag")
+	flag.DurationVar(&stdio.SendDelay, "send-delay", 0, "Pause between -send-file files, i.e. 500ms")
+	flag.StringVar(&stdio.ExitOn, "exit-on", "", "Close connection and exit once received data contains given pattern, i.e. \"$ \"")
+	flag.BoolVar(&stdio.ExitOnRegex, "exit-on-regex", false, "Treat -exit-on pattern as regular expression")
+	flag.BoolVar(&transport.Progress, "progress", false, "Render progress bar of -send-file transfer (or byte counters otherwise) on stderr if it's a terminal")
+	flag.StringVar(&deadline, "deadline", "", "Wall-clock time (RFC3339) to close connection at regardless of activity, i.e. 2016-09-22T18:00:00+03:00")
+	flag.BoolVar(&transport.QuietEOF, "quiet-eof", false, "Don't log normal end of transfer, errors are still logged and make exit status non-zero")
+	flag.DurationVar(&stdio.CommandTimeout, "command-timeout", 0, "Terminate -exec command once it has run for given duration regardless of connection state, i.e. 1m")
+	flag.BoolVar(&udp.PerPeer, "listen-udp-per-peer", false, "Serve every remote address in its own session with its own local side in UDP listen mode")
+	flag.StringVar(&stdio.SendURL, "send-url", "", "URL to fetch and send its body instead of standard input, i.e. http://host/payload.bin")
+	flag.BoolVar(&stdio.StripANSI, "strip-ansi", false, "Strip ANSI escape sequences (colors, cursor movement) from received data")
+	flag.StringVar(&pidFile, "pidfile", "", "File to write process ID to, it's removed on exit")
+	flag.StringVar(&stdio.LogFile, "log-file", "", "File to append log to instead of standard error")
+	flag.Int64Var(&stdio.LogMaxSize, "log-max-size", 0, "Size of log file in bytes to rotate it at, the previous one is kept with .1 suffix, no rotation if zero")
+	flag.BoolVar(&udp.StrictPeer, "strict-udp-peer", false, "Warn about datagrams from sources other than the first peer in UDP listen mode")
+	flag.StringVar(&tcp.TLSMinVersion, "min-tls", "", "Minimum TLS version to negotiate, i.e. 1.2")
+	flag.StringVar(&tcp.TLSMaxVersion, "max-tls", "", "Maximum TLS version to negotiate, i.e. 1.3")
+	flag.StringVar(&tcp.TLSCipherSuites, "cipher-suites", "", "Comma-separated TLS 1.2 and older cipher suites to offer or accept, i.e. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	flag.IntVar(&stdio.Tail, "tail", 0, "Print only the last given number of received bytes once connection is closed, i.e. 4096")
+	flag.BoolVar(&noDNS, "no-dns", false, "Refuse to resolve host names, every address to connect to or through (-host, -targets-file, -connect-via and so on) must be literal IP address")
+	flag.StringVar(&stdio.Transcript, "transcript", "", "File to write human-readable timestamped log of both directions to, binary data is shown as hex")
+	flag.DurationVar(&udp.ReconnectOnIdle, "reconnect-on-idle", 0, "Resolve -host again once nothing has been transferred for given period in UDP client mode, reconnect if address has changed, i.e. 30s")
+	flag.Float64Var(&udp.DropRate, "drop-rate", 0, "Fraction of UDP datagrams to drop to simulate packet loss, i.e. 0.1")
+	flag.Float64Var(&udp.DupRate, "dup-rate", 0, "Fraction of UDP datagrams to forward twice, i.e. 0.05")
+	flag.DurationVar(&udp.DelayJitter, "delay-jitter", 0, "Maximum random delay added before every UDP datagram is forwarded, i.e. 50ms")
+	flag.StringVar(&udp.ImpairDirection, "impair-direction", "both", "Datagrams to apply -drop-rate, -dup-rate and -delay-jitter to: send, receive or both")
+	flag.BoolVar(&stdio.Pausable, "pausable", false, "Pause and resume output of received data on SIGUSR2, connection isn't read while paused")
+	flag.DurationVar(&transport.AcceptTimeout, "accept-timeout", 0, "Exit with error if no connection (or datagram) has been received within given period in TCP/UDP listen mode, i.e. 30s")
+	flag.BoolVar(&selfTest, "selftest", false, "Pump 64MB pattern through loopback TCP connection both ways, verify it and report throughput")
+	flag.DurationVar(&tcp.FirstByteTimeout, "first-byte-timeout", 0, "Close TCP connection if nothing has been received within given period since it's opened, i.e. 3s")
+	flag.DurationVar(&tcp.IdleTimeout, "idle-timeout", 0, "Close TCP connection if nothing has been received for given period after the first byte, i.e. 5m")
+	flag.StringVar(&tcp.Upstream, "listen-then-connect", "", "Relay every accepted connection to its own connection to given upstream dialed once it has been accepted in TCP listen mode, i.e. db.internal:5432")
+	flag.IntVar(&tcp.MaxConns, "max-conns", 0, "Maximum number of connections relayed at once by -listen-then-connect, no limit if zero")
+	flag.BoolVar(&udpProbe, "udp-probe", false, "Report whether UDP port is open, closed or open|filtered by sending -payload-hex/-payload-file (or empty datagram) and waiting -timeout for response")
+	flag.IntVar(&tcp.Retry, "retry", 0, "Number of retries of -udp-probe without response or of connection refused with -wait-for-listener")
+	flag.BoolVar(&tcp.RetryOnReset, "retry-on-reset", false, "Connect again after -retry-interval and go on receiving once TCP connection has been reset by peer, data in flight is lost")
+	flag.BoolVar(&tcp.WaitForListener, "wait-for-listener", false, "Retry TCP connection up to -retry times while it's refused, fail at once on other errors")
+	flag.DurationVar(&tcp.RetryInterval, "retry-interval", time.Second, "Pause before every retry of -wait-for-listener and -retry-on-reset")
+	flag.IntVar(&stdio.BufferQueue, "buffer-queue", 0, "Number of received chunks to queue between connection and standard output, i.e. 1024")
+	flag.StringVar(&stdio.Overflow, "overflow", "block", "What to do once -buffer-queue is full: block (wait for output) or drop (drop the oldest chunk)")
+	flag.StringVar(&udp.DisconnectMatch, "udp-disconnect-match", "trimmed", "How UDP datagram is compared to disconnect sequence: trimmed (trailing line ending and whitespace are ignored) or exact")
+	flag.IntVar(&transport.ReadSize, "read-size", 0, "Maximum number of bytes every read (and so every write) moves to force fragmentation, i.e. 1, no limit if zero")
+	flag.BoolVar(&tcp.ProxyProtocolV2, "proxy-protocol-v2", false, "Send PROXY protocol v2 header first over TCP connection, relay sends client addresses to upstream")
+	flag.Var(&tcp.ProxyTLVs, "proxy-tlv", "TLV to add to PROXY protocol v2 header as type=value, type and value may be 0x prefixed hex, repeated flag, i.e. 0xea=vpce-0123")
+	flag.BoolVar(&transport.CountLines, "count-lines", false, "Log number of lines received and sent once connection is closed, final unterminated line is counted too")
+	flag.BoolVar(&tcp.Telnet, "telnet", false, "Strip Telnet IAC sequences from received data, answer option negotiation and escape IAC in sent data")
+	flag.StringVar(&tcp.TelnetPolicy, "telnet-policy", "refuse", "Reply to Telnet option negotiation: refuse (WONT/DONT) or ignore (no reply)")
+	flag.StringVar(&tcp.SourcePortRange, "source-port-range", "", "Range of local ports to bind outgoing TCP connections to, every connection (see -connections) gets its own port, i.e. 20000-20100")
+	flag.IntVar(&stdio.MaxLineLength, "max-line-length", 1<<20, "Maximum length of line read by -hex-send and -json-lines, longer hex line is decoded in parts of this size, longer JSON line is skipped")
+	flag.BoolVar(&tcp.InspectTLS, "inspect-tls", false, "Log content type, version and length of every TLS record received over plaintext TCP connection, data is passed as is")
+	flag.BoolVar(&stdio.TeeHex, "tee-hex", false, "Dump received data to standard error as canonical hexdump besides writing it as is to standard output")
+	flag.StringVar(&tcp.ConnectVia, "connect-via", "", "Comma-separated relay hops started with -chain-relay to connect through in TCP client mode, i.e. relay1:9000,relay2:9000")
+	flag.BoolVar(&tcp.ChainRelay, "chain-relay", false, "Relay every accepted connection to upstream given by -connect-via client in TCP listen mode")
+	flag.Uint64Var(&transport.ExpectBytes, "expect-bytes", 0, "Number of bytes remote peer is expected to send, earlier end of transfer is logged as truncated one")
+	flag.BoolVar(&transport.StrictEOF, "strict-eof", false, "Treat transfer truncated before -expect-bytes as error, exit status is non-zero then")
+	flag.BoolVar(&stdio.EnvExpand, "env-expand", false, "Expand environment variables, ${NONCE}, ${TIMESTAMP} and ${TIMESTAMP_MS} in -payload-hex, -handshake-hex and -probe-payload")
+	flag.StringVar(&stdio.DiscardUntil, "discard-until", "", "Discard received data until given pattern (inclusive), print the rest as usual, i.e. \"login: \"")
+	flag.BoolVar(&tcp.TCPInfo, "tcp-info", false, "Log kernel statistics of TCP connection (RTT, retransmits, congestion window) before it's closed, Linux only")
+	flag.StringVar(&stdio.ExecStderr, "exec-stderr", "local", "Where error output of -exec command goes: local (standard error), conn (remote peer, with output) or null")
+	flag.StringVar(&targetsFile, "targets-file", "", "File with host:port per line to connect to one by one in TCP client mode, every target gets the same standard input (or -send-file)")
+	flag.BoolVar(&stdio.ConfirmEOF, "confirm-eof", false, "Disconnect only on the second Ctrl-D pressed within 3s if standard input is a terminal")
+	flag.StringVar(&tcp.TCPMD5, "tcp-md5", "", "TCP MD5 signature key (RFC 2385) of connection to or (in listen mode) from -host, Linux only")
+	flag.StringVar(&stdio.SplitOutput, "split-output", "", "Prefix of files to write received data to instead of standard output, i.e. capture makes capture.000, capture.001 and so on")
+	flag.Int64Var(&stdio.SplitSize, "split-size", 0, "Size in bytes to start the next -split-output file at, i.e. 104857600")
+	flag.DurationVar(&stdio.SplitInterval, "split-interval", 0, "Period to start the next -split-output file after, i.e. 1h")
+	flag.StringVar(&transport.AddressFilter, "address-filter", "", "Network (CIDR) to pick remote address from if -host resolves to several addresses in TCP/UDP client mode, i.e. 10.1.0.0/16")
+	flag.DurationVar(&tcp.WriteCoalesce, "write-coalesce", 0, "Gather small writes of sent data for given period (or up to 16KB) and send them together in TCP mode, i.e. 10ms")
+	flag.StringVar(&tcp.TLSPin, "tls-pin", "", "SHA-256 fingerprint of server certificate to trust regardless of CA, i.e. sha256:3f2a...")
+	flag.StringVar(&tcp.TLSPinSPKI, "tls-pin-spki", "", "SHA-256 fingerprint of server public key (SubjectPublicKeyInfo) to trust regardless of CA, i.e. sha256:9b1c...")
+	flag.BoolVar(&tcp.CloseAfterSend, "close-after-send", false, "Close TCP connection once standard input is over without waiting for anything else to be received")
+	flag.BoolVar(&stdio.NoStdin, "no-stdin", false, "Don't read standard input, only receive data until remote peer closes connection")
 	flag.Parse()
+	if err := stdio.OpenLog(); err != nil {
+		log.Fatalln(err)
+	}
+	transport.WatchSnapshots()
+	if stdio.EnvExpand {
+		payloadHex, handshakeHex = stdio.ExpandHex(payloadHex), stdio.ExpandHex(handshakeHex)
+		udp.ProbePayload = stdio.Expand(udp.ProbePayload)
+	}
 
+	if resolveOnly {
+		resolve(host, port)
+		return
+	}
+	if selfTest {
+		if err := tcp.SelfTest(); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	if err := stdio.Check(); err != nil {
+		log.Fatalln(err)
+	}
+	// It's deferred first, so everything else is done before exit
+	defer func() {
+		if (transport.QuietEOF || transport.StrictEOF) && transport.Failed() || stdio.InvalidInput() {
+			os.Exit(1)
+		}
+	}()
+	if err := udp.Check(); err != nil {
+		log.Fatalln(err)
+	}
+	defer stdio.Restore()
+	defer transport.WaitHooks()
+	payload, err := stdio.ReadPayload(payloadFile, payloadHex)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	udp.Payload = payload
+	if tcp.Handshake, err = stdio.ReadPayload(handshakeFile, handshakeHex); err != nil {
+		log.Fatalln(err)
+	}
+	if metricsAddr != "" {
+		metrics.Start(metricsAddr)
+	}
+	var targets []string
+	if targetsFile != "" {
+		if targets, err = readTargets(targetsFile); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if noDNS {
+		for _, h := range dialedHosts(listen, host, targets) {
+			if net.ParseIP(strings.SplitN(strings.Trim(h, "[]"), "%", 2)[0]) == nil {
+				log.Fatalf("Host %q isn't literal IP address, resolution is disabled by -no-dns\n", h)
+			}
+		}
+	}
+	// net package takes IP version suffix as is, family selects the transport
+	family := proto
 	switch proto {
+	case "tcp4", "tcp6":
+		family = "tcp"
+	case "udp4", "udp6":
+		family = "udp"
+	}
+	if tunnel.Server != "" && (listen || family != "tcp") {
+		log.Fatalln("SSH tunnel is supported in TCP client mode only")
+	}
+	if deadline != "" {
+		t, err := time.Parse(time.RFC3339, deadline)
+		if err != nil {
+			log.Fatalln("Invalid deadline:", err)
+		}
+		if time.Until(t) <= 0 {
+			log.Fatalln("Deadline has already come")
+		}
+		transport.Deadline = t
+	}
+	// Output of executed command is over once it has exited, connection is closed then anyway.
+	// Fire-and-forget sender closes connection once input is over in either mode.
+	// Relayed connection is closed once upstream has closed its one.
+	transport.KeepOnEOF = listen && !closeOnEOF && !tcp.CloseAfterSend && stdio.Exec == "" && !tcp.Relaying()
+	transport.ProgressTotal = stdio.SendSize()
+	bind := port
+	if listenAddress != "" && (!listen || family != "tcp" && family != "udp") {
+		log.Fatalln("Listen address is supported in TCP/UDP listen mode only")
+	}
+	if listenAddress != "" {
+		if err := transport.CheckLocal(listenAddress); err != nil {
+			log.Fatalln(err)
+		}
+		bind = transport.Address(listenAddress, port)
+	}
+	if tcp.TLS && family != "tcp" && proto != "auto" {
+		log.Fatalln("TLS is supported in TCP mode only")
+	}
+	if (tcp.TLSPin != "" || tcp.TLSPinSPKI != "") && (!tcp.TLS || listen || tcp.TLSInsecure) {
+		log.Fatalln("TLS pin is supported in TLS client mode with server verification only")
+	}
+	if err := tcp.CheckTLS(); err != nil {
+		log.Fatalln(err)
+	}
+	if tcp.Telnet && (family != "tcp" || tcp.Relaying()) {
+		log.Fatalln("Telnet is supported in TCP mode only, relay passes data as is")
+	}
+	if err := tcp.CheckTelnet(); err != nil {
+		log.Fatalln(err)
+	}
+	if tcp.SourcePortRange != "" && (listen || family != "tcp" || tunnel.Server != "") {
+		log.Fatalln("Source port range is supported in TCP client mode without SSH tunnel only")
+	}
+	if err := tcp.CheckSourcePorts(); err != nil {
+		log.Fatalln(err)
+	}
+	if len(tcp.Handshake) > 0 && (!listen || family != "tcp" || tcp.Relaying()) {
+		log.Fatalln("Handshake is supported in TCP listen mode only")
+	}
+	if tcp.InspectTLS && (tcp.TLS || family != "tcp") {
+		log.Fatalln("TLS inspection is supported in plaintext TCP mode only")
+	}
+	if tcp.RetryOnReset && (listen || family != "tcp" || connections > 1) {
+		log.Fatalln("Retry on reset is supported in TCP client mode with single connection only")
+	}
+	if tcp.RetryOnReset && tcp.NagleOffAfter > 0 {
+		log.Fatalln("Retry on reset can't be combined with -nagle-off-after, bursts are tracked on socket of the first connection")
+	}
+	if tcp.ConnectVia != "" && (listen || family != "tcp") {
+		log.Fatalln("Relay hops are supported in TCP client mode only")
+	}
+	if transport.StrictEOF && transport.ExpectBytes == 0 {
+		log.Fatalln("Strict EOF requires -expect-bytes")
+	}
+	if tcp.TCPInfo && (runtime.GOOS != "linux" || family != "tcp") {
+		log.Fatalln("TCP info is supported in TCP mode on Linux only")
+	}
+	if tcp.TCPMD5 != "" && (runtime.GOOS != "linux" || family != "tcp" || tunnel.Server != "") {
+		log.Fatalln("TCP MD5 signature is supported in TCP mode without SSH tunnel on Linux only")
+	}
+	tcp.MD5Peer = strings.Trim(host, "[]")
+	if err := tcp.CheckMD5(listen); err != nil {
+		log.Fatalln(err)
+	}
+	if transport.AddressFilter != "" && (listen || family != "tcp" && family != "udp" || tunnel.Server != "") {
+		log.Fatalln("Address filter is supported in TCP/UDP client mode without SSH tunnel only")
+	}
+	if err := transport.CheckAddressFilter(); err != nil {
+		log.Fatalln(err)
+	}
+	if tcp.WriteCoalesce > 0 && family != "tcp" {
+		log.Fatalln("Write coalescing is supported in TCP mode only, it would merge UDP datagrams")
+	}
+	if transport.AdaptiveBuffer && family != "tcp" {
+		log.Fatalln("Adaptive buffer is supported in TCP mode only, UDP always uses full-size buffer")
+	}
+	if tcp.CloseAfterSend && (family != "tcp" || stdio.NoStdin || tcp.Relaying()) {
+		log.Fatalln("Closing after send is supported in TCP mode with standard input only")
+	}
+	if tcp.WaitForListener && (tcp.Retry <= 0 || listen) {
+		log.Fatalln("Waiting for listener requires -retry and client mode")
+	}
+	if tcp.Upstream != "" && tcp.ChainRelay {
+		log.Fatalln("Chain relay takes upstream from chain header, -listen-then-connect can't be set")
+	}
+	if tcp.Relaying() && (!listen || family != "tcp" || stdio.Mode != "stdio" || stdio.Exec != "") {
+		log.Fatalln("Relay is supported in TCP listen mode only, local side isn't used")
+	}
+	if connections > 1 && (listen || family != "tcp" || stdio.Mode != "stdio" || stdio.Exec != "" || stdio.RawStdin) {
+		log.Fatalln("Parallel connections are supported in TCP client mode with standard input and output only")
+	}
+	if targetsFile != "" && (listen || host != "" || family != "tcp" || connections > 1 || stdio.Mode != "stdio" || stdio.Exec != "" || stdio.RawStdin) {
+		log.Fatalln("Targets file is supported in TCP client mode without -host, standard input or files are sent to every target")
+	}
+	if pidFile != "" {
+		remove, err := writePIDFile(pidFile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer remove()
+		stdio.OnSignal(remove)
+	}
+
+	if udpProbe {
+		if listen || family != "udp" || host == "" {
+			log.Fatalln("UDP probe is supported in UDP client mode only")
+		}
+		if !udp.Probe(proto, host, port, tcp.Timeout, tcp.Retry) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	switch family {
 	case "tcp":
-		if listen {
-			tcp.StartServer(proto, port)
+		if listen && tcp.Relaying() {
+			tcp.StartRelay(proto, bind)
+		} else if listen {
+			tcp.StartServer(proto, bind)
+		} else if targetsFile != "" {
+			tcp.StartTargets(proto, targets)
+		} else if host != "" && connections > 1 {
+			tcp.StartClients(proto, host, port, connections)
 		} else if host != "" {
 			tcp.StartClient(proto, host, port)
 		} else {
@@ -27,13 +357,152 @@ func main() {
 		}
 	case "udp":
 		if listen {
-			udp.StartServer(proto, port)
+			udp.StartServer(proto, bind)
 		} else if host != "" {
 			udp.StartClient(proto, host, port)
 		} else {
 			flag.Usage()
 		}
+	case "npipe":
+		if listen {
+			npipe.StartServer(port)
+		} else {
+			npipe.StartClient(port)
+		}
+	case "vsock":
+		if listen {
+			vsock.StartServer(port)
+		} else {
+			vsock.StartClient(port)
+		}
+	case "auto":
+		if !listen && host != "" {
+			startAuto(strings.Split(order, ","), host, port)
+		} else {
+			flag.Usage()
+		}
 	default:
 		flag.Usage()
 	}
 }
+
+// startAuto tries transports in given order and transfers data over the first one which has connected.
+// UDP has no handshake, so UDP connection always succeeds unless host can't be resolved.
+func startAuto(order []string, host string, port string) {
+	addr := transport.Address(host, port)
+	for _, proto := range order {
+		started := time.Now()
+		switch proto {
+		case "tcp":
+			con, err := tcp.Dial(proto, host, port)
+			if err != nil {
+				log.Printf("TCP connection to %s has failed: %s\n", addr, err)
+				continue
+			}
+			s := transport.Dialed(con, started)
+			s.Printf("Connected to %s over TCP\n", addr)
+			tcp.LogTLS(s, con)
+			tcp.TransferStreams(con)
+			return
+		case "udp":
+			con, err := udp.Dial(proto, host, port)
+			if err != nil {
+				log.Printf("UDP connection to %s has failed: %s\n", addr, err)
+				continue
+			}
+			transport.Dialed(con, started).Printf("Sending datagrams to %s over UDP\n", addr)
+			udp.TransferPackets(con)
+			return
+		default:
+			log.Fatalf("Unknown transport %q in -auto-order\n", proto)
+		}
+	}
+	log.Fatalln("No transport has succeeded")
+}
+
+// resolve prints every address host resolves to, SRV records are looked up for _service._proto.name hosts
+func resolve(host string, port string) {
+	if host == "" {
+		log.Fatalln("Host to resolve is empty")
+	}
+	if strings.HasPrefix(host, "_") {
+		_, srvs, err := net.LookupSRV("", "", host)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, srv := range srvs {
+			fmt.Printf("SRV %s:%d priority %d weight %d\n", srv.Target, srv.Port, srv.Priority, srv.Weight)
+		}
+		return
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, ip := range ips {
+		kind := "AAAA"
+		if ip.To4() != nil {
+			kind = "A"
+		}
+		fmt.Println(kind, net.JoinHostPort(ip.String(), strings.TrimPrefix(port, ":")))
+	}
+}
+
+// writePIDFile writes process ID to path, returned function removes it
+func writePIDFile(path string) (remove func(), err error) {
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644); err != nil {
+		return nil, fmt.Errorf("Unable to write PID file: %s", err)
+	}
+	return func() { os.Remove(path) }, nil
+}
+
+// readTargets reads host:port per line, blank lines and lines starting with # are skipped
+func readTargets(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read targets: %s", err)
+	}
+	var targets []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			targets = append(targets, line)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("There are no targets in %s", path)
+	}
+	return targets, nil
+}
+
+// dialedHosts returns hosts of every address given to connect to or through, ports and SSH user name are dropped
+func dialedHosts(listen bool, host string, targets []string) []string {
+	var hosts []string
+	add := func(addr string) {
+		if h, _, err := net.SplitHostPort(addr); err == nil {
+			addr = h
+		}
+		hosts = append(hosts, addr)
+	}
+	if !listen && host != "" {
+		hosts = append(hosts, host)
+	}
+	for _, target := range targets {
+		add(target)
+	}
+	if tcp.ConnectVia != "" {
+		for _, hop := range strings.Split(tcp.ConnectVia, ",") {
+			add(hop)
+		}
+	}
+	if tcp.Upstream != "" {
+		add(tcp.Upstream)
+	}
+	if tunnel.Server != "" {
+		add(tunnel.Server[strings.LastIndex(tunnel.Server, "@")+1:])
+	}
+	if u, err := url.Parse(stdio.SendURL); err == nil && stdio.SendURL != "" {
+		hosts = append(hosts, u.Hostname())
+	}
+	return hosts
+}
@@ -1,11 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dddpaul/gonc/tcp"
+	"github.com/dddpaul/gonc/transport"
 	"github.com/dddpaul/gonc/udp"
 	"github.com/stretchr/testify/assert"
 )
@@ -13,6 +24,9 @@ import (
 var Host = "127.0.0.1"
 var Port = ":9991"
 var Input = "Input from other side, пока, £, 语汉"
+var DisconnectLine = udp.DisconnectSequence + "\n"
+var DisconnectPort = ":9993"
+var ClosedPort = ":9994"
 
 func TestTransferStreams(t *testing.T) {
 	w, oldStdin := mockStdin(t)
@@ -74,3 +88,286 @@ func mockStdin(t *testing.T) (w *os.File, oldStdin *os.File) {
 	os.Stdin = r
 	return
 }
+
+func TestTransferStreamsInMemory(t *testing.T) {
+	tests := []struct {
+		name     string
+		con      *transport.Memory
+		in       *transport.Memory
+		received string
+		sent     string
+	}{
+		{"EOF", transport.NewMemory(nil, "response"), transport.NewMemory(nil, "request"), "response", "request"},
+		{"partial reads", transport.NewMemory(nil, "resp", "on", "se"), transport.NewMemory(nil, "req", "uest"), "response", "request"},
+		{"remote error", transport.NewMemory(errors.New("connection reset"), "resp"), transport.NewMemory(nil, "request"), "resp", "request"},
+		{"local error", transport.NewMemory(nil, "response"), transport.NewMemory(errors.New("read failed"), "req"), "response", "req"},
+		{"nothing to transfer", transport.NewMemory(nil), transport.NewMemory(nil), "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := transport.NewMemory(nil)
+			tcp.Transfer(hold(tt.con), tt.in, out)
+			assert.Equal(t, tt.received, out.Written.String())
+			assert.Equal(t, tt.sent, tt.con.Written.String())
+		})
+	}
+}
+
+func TestTransferStreamsKeepOnEOF(t *testing.T) {
+	defer func(k bool) { transport.KeepOnEOF = k }(transport.KeepOnEOF)
+	transport.KeepOnEOF = true
+
+	local, remote := net.Pipe()
+	// Remote peer responds once request has been received, local input is over by then
+	go func() {
+		buf := make([]byte, 3)
+		io.ReadFull(remote, buf)
+		time.Sleep(10 * time.Millisecond)
+		remote.Write([]byte("response"))
+		remote.Close()
+	}()
+	out := transport.NewMemory(nil)
+	tcp.Transfer(local, transport.NewMemory(nil, "req"), out)
+	assert.Equal(t, "response", out.Written.String())
+}
+
+func TestTransferStreamsTLSWarning(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	tests := []struct {
+		name     string
+		received string
+		warning  bool
+	}{
+		{"server hello", "\x16\x03\x03\x00\x5a\x02", true},
+		{"alert", "\x15\x03\x01\x00\x02\x02\x28", true},
+		{"plaintext", "HTTP/1.1 400 Bad Request\r\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			log.SetOutput(&logged)
+			out := transport.NewMemory(nil)
+			tcp.Transfer(transport.NewMemory(nil, tt.received), transport.NewMemory(nil), out)
+			// Data is forwarded as is regardless of warning
+			assert.Equal(t, tt.received, out.Written.String())
+			assert.Equal(t, tt.warning, strings.Contains(logged.String(), "looks like TLS"))
+		})
+	}
+}
+
+func TestTransferStreamsNoLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// Input is never written just like idle terminal
+	in, _ := io.Pipe()
+	out := transport.NewMemory(nil)
+	tcp.Transfer(transport.NewMemory(nil, "response"), in, out)
+
+	assert.Equal(t, "response", out.Written.String())
+	// Polled right here, helper goroutine of assert.Eventually would be counted itself
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func TestTransferPacketsInMemory(t *testing.T) {
+	tests := []struct {
+		name     string
+		con      *transport.Memory
+		in       *transport.Memory
+		received string
+		sent     string
+	}{
+		{"EOF", transport.NewMemory(nil, "one\n", "two\n"), transport.NewMemory(nil, "three\n"), "one\ntwo\n", "three\n"},
+		{"remote error", transport.NewMemory(errors.New("connection refused"), "one\n"), transport.NewMemory(nil, "three\n"), "one\n", "three\n"},
+		{"remote disconnect", transport.NewMemory(nil, "one\n", DisconnectLine, "two\n"), transport.NewMemory(nil), "one\n", ""},
+		{"local disconnect", transport.NewMemory(nil, "one\n"), transport.NewMemory(nil, "three\n", DisconnectLine, "four\n"), "one\n", "three\n"},
+		{"empty datagram", transport.NewMemory(nil, "", "one\n", "", DisconnectLine), transport.NewMemory(nil, "", "three\n"), "one\n", "three\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := transport.NewMemory(nil)
+			udp.Transfer(hold(tt.con), tt.in, out)
+			assert.Equal(t, tt.received, out.Written.String())
+			assert.Equal(t, tt.sent, tt.con.Written.String())
+		})
+	}
+}
+
+func TestTransferPacketsKeepOnEOF(t *testing.T) {
+	defer func(k bool) { transport.KeepOnEOF = k }(transport.KeepOnEOF)
+	transport.KeepOnEOF = true
+
+	local, remote := net.Pipe()
+	// Remote peer responds once request has been received, local input is over by then
+	go func() {
+		buf := make([]byte, 4)
+		io.ReadFull(remote, buf)
+		time.Sleep(10 * time.Millisecond)
+		remote.Write([]byte("response\n"))
+		remote.Write([]byte(DisconnectLine))
+	}()
+	out := transport.NewMemory(nil)
+	udp.Transfer(local, transport.NewMemory(nil, "req\n"), out)
+	assert.Equal(t, "response\n", out.Written.String())
+}
+
+func TestTransferPacketsDisconnectMatch(t *testing.T) {
+	defer func() { udp.DisconnectMatch = "trimmed" }()
+	tests := []struct {
+		match      string
+		datagram   string
+		disconnect bool
+	}{
+		{"trimmed", "~.\n", true},
+		{"trimmed", "~.\r\n", true},
+		{"trimmed", "~. \n", true},
+		{"trimmed", "~.", true},
+		{"trimmed", "~.x", false},
+		{"exact", "~.", true},
+		{"exact", "~.\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.match+" "+strconv.Quote(tt.datagram), func(t *testing.T) {
+			udp.DisconnectMatch = tt.match
+			con := transport.NewMemory(nil, "one\n", tt.datagram, "two\n")
+			out := transport.NewMemory(nil)
+			udp.Transfer(hold(con), transport.NewMemory(nil), out)
+			if tt.disconnect {
+				assert.Equal(t, "one\n", out.Written.String())
+			} else {
+				assert.Equal(t, "one\n"+tt.datagram+"two\n", out.Written.String())
+			}
+		})
+	}
+}
+
+func TestTransferPacketsRemoteDisconnect(t *testing.T) {
+	addr, err := net.ResolveUDPAddr("udp", DisconnectPort)
+	assert.Nil(t, err)
+	server, err := net.ListenUDP("udp", addr)
+	assert.Nil(t, err)
+
+	// Input is never written just like idle terminal, disconnect datagram must stop sending anyway
+	in, _ := io.Pipe()
+	out := transport.NewMemory(nil)
+	done := make(chan struct{})
+	go func() {
+		udp.Transfer(server, in, out)
+		close(done)
+	}()
+
+	client, err := net.Dial("udp", Host+DisconnectPort)
+	assert.Nil(t, err)
+	defer client.Close()
+	_, err = client.Write([]byte(Input))
+	assert.Nil(t, err)
+	_, err = client.Write([]byte(DisconnectLine))
+	assert.Nil(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Session hasn't been ended by disconnect datagram")
+	}
+	assert.Equal(t, Input, out.Written.String())
+}
+
+func TestTransferPacketsPortUnreachable(t *testing.T) {
+	defer func(k bool) { transport.KeepOnEOF = k }(transport.KeepOnEOF)
+	transport.KeepOnEOF = true
+	defer log.SetOutput(os.Stderr)
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+
+	// Nothing is listening on this port, so kernel reports ICMP port unreachable on the next read
+	con, err := udp.Dial("udp", Host, ClosedPort)
+	assert.Nil(t, err)
+	udp.Transfer(con, transport.NewMemory(nil, Input), transport.NewMemory(nil))
+	assert.Contains(t, logged.String(), "Destination port unreachable")
+}
+
+func TestAddress(t *testing.T) {
+	defer func(i string) { transport.Interface = i }(transport.Interface)
+	transport.Interface = "eth0"
+	tests := []struct {
+		host string
+		addr string
+	}{
+		{"127.0.0.1", "127.0.0.1:9991"},
+		{"localhost", "localhost:9991"},
+		{"::1", "[::1]:9991"},
+		{"[::1]", "[::1]:9991"},
+		{"fe80::1", "[fe80::1%eth0]:9991"},
+		{"fe80::1%lo", "[fe80::1%lo]:9991"},
+		{"[fe80::1%lo]", "[fe80::1%lo]:9991"},
+		{"ff02::1", "[ff02::1%eth0]:9991"},
+		{"2001:db8::1", "[2001:db8::1]:9991"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.addr, transport.Address(tt.host, Port))
+		})
+	}
+}
+
+func TestResolveZone(t *testing.T) {
+	defer func(i string) { transport.Interface = i }(transport.Interface)
+	transport.Interface = "eth0"
+
+	addr, err := net.ResolveUDPAddr("udp", transport.Address("fe80::1", Port))
+	assert.Nil(t, err)
+	assert.Equal(t, "eth0", addr.Zone)
+
+	addr, err = net.ResolveUDPAddr("udp", transport.Address("fe80::1%lo", Port))
+	assert.Nil(t, err)
+	assert.Equal(t, "lo", addr.Zone)
+}
+
+func BenchmarkTransferStreams(b *testing.B) {
+	defer func(a bool) { transport.AdaptiveBuffer = a }(transport.AdaptiveBuffer)
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	for _, adaptive := range []bool{false, true} {
+		for _, size := range []int{64, 64 << 10} {
+			chunks := make([]string, 100)
+			for i := range chunks {
+				chunks[i] = strings.Repeat("x", size)
+			}
+			b.Run(fmt.Sprintf("adaptive=%t/chunk=%d", adaptive, size), func(b *testing.B) {
+				transport.AdaptiveBuffer = adaptive
+				b.ReportAllocs()
+				b.SetBytes(int64(size * len(chunks)))
+				for i := 0; i < b.N; i++ {
+					tcp.Transfer(transport.NewMemory(nil, chunks...), transport.NewMemory(nil), transport.NewMemory(nil))
+				}
+			})
+		}
+	}
+}
+
+// heldConn returns the last chunk and the end of in-memory connection only once it has been closed, like remote peer
+// which responds to the whole request, so sending direction is always done before receiving one whatever goroutine runs first
+type heldConn struct {
+	*transport.Memory
+	done chan struct{}
+	once sync.Once
+}
+
+func hold(m *transport.Memory) *heldConn {
+	return &heldConn{Memory: m, done: make(chan struct{})}
+}
+
+func (h *heldConn) Read(b []byte) (int, error) {
+	if len(h.Chunks) <= 1 {
+		<-h.done
+	}
+	return h.Memory.Read(b)
+}
+
+func (h *heldConn) Close() error {
+	h.once.Do(func() { close(h.done) })
+	return nil
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// Connections counts every accepted or dialed connection
+	Connections = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gonc_connections_total",
+		Help: "Total number of connections.",
+	})
+	// Active is a number of connections being transferred right now
+	Active = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gonc_connections_active",
+		Help: "Number of active connections.",
+	})
+	// Bytes counts transferred bytes by direction (sent or received)
+	Bytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gonc_bytes_total",
+		Help: "Total number of transferred bytes.",
+	}, []string{"direction"})
+	// Errors counts transfer errors
+	Errors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gonc_errors_total",
+		Help: "Total number of transfer errors.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(Connections, Active, Bytes, Errors)
+}
+
+// Start serves metrics for Prometheus at addr/metrics in background, server is gone together with the process
+func Start(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		log.Fatalln(http.ListenAndServe(addr, mux))
+	}()
+	log.Println("Serving metrics on", addr)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package npipe
+
+import "log"
+
+// StartServer is not available, named pipes are supported on Windows only
+func StartServer(path string) {
+	log.Fatalln("Named pipes are supported on Windows only")
+}
+
+// StartClient is not available, named pipes are supported on Windows only
+func StartClient(path string) {
+	log.Fatalln("Named pipes are supported on Windows only")
+}
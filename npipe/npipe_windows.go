@@ -0,0 +1,36 @@
+package npipe
+
+import (
+	"log"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/dddpaul/gonc/tcp"
+	"github.com/dddpaul/gonc/transport"
+)
+
+// StartServer starts named pipe listener
+func StartServer(path string) {
+	ln, err := winio.ListenPipe(path, nil)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Println("Listening on", path)
+	con, err := ln.Accept()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	transport.Open(con).Printf("Connection has been opened\n")
+	tcp.TransferStreams(con)
+}
+
+// StartClient starts named pipe connector
+func StartClient(path string) {
+	started := time.Now()
+	con, err := winio.DialPipe(path, nil)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	transport.Dialed(con, started).Printf("Connected to %s\n", path)
+	tcp.TransferStreams(con)
+}
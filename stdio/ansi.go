@@ -0,0 +1,78 @@
+package stdio
+
+import "io"
+
+// StripANSI makes escape sequences (colors, cursor movement, terminal titles) to be removed from received data
+var StripANSI bool
+
+const (
+	ansiText = iota
+	// ansiEscape follows ESC, next byte defines kind of sequence
+	ansiEscape
+	// ansiCSI is inside of control sequence (i.e. SGR), it's terminated by byte from @ to ~
+	ansiCSI
+	// ansiOSC is inside of operating system command, it's terminated by BEL or ST (ESC \)
+	ansiOSC
+	ansiOSCEscape
+)
+
+// ansiStripper drops escape sequences before writing, state is kept between writes so sequences may be split across reads
+type ansiStripper struct {
+	w     io.WriteCloser
+	state int
+	buf   []byte
+}
+
+func newANSIStripper(w io.WriteCloser) *ansiStripper {
+	return &ansiStripper{w: w}
+}
+
+func (a *ansiStripper) Write(b []byte) (int, error) {
+	a.buf = a.buf[:0]
+	for _, c := range b {
+		switch a.state {
+		case ansiText:
+			if c == 0x1b {
+				a.state = ansiEscape
+			} else {
+				a.buf = append(a.buf, c)
+			}
+		case ansiEscape:
+			switch c {
+			case '[':
+				a.state = ansiCSI
+			case ']':
+				a.state = ansiOSC
+			default:
+				// Two-byte sequence, i.e. ESC M (reverse index)
+				a.state = ansiText
+			}
+		case ansiCSI:
+			if c >= 0x40 && c <= 0x7e {
+				a.state = ansiText
+			}
+		case ansiOSC:
+			if c == 0x07 {
+				a.state = ansiText
+			} else if c == 0x1b {
+				a.state = ansiOSCEscape
+			}
+		case ansiOSCEscape:
+			if c == '\\' {
+				a.state = ansiText
+			} else {
+				a.state = ansiOSC
+			}
+		}
+	}
+	if len(a.buf) > 0 {
+		if _, err := a.w.Write(a.buf); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (a *ansiStripper) Close() error {
+	return a.w.Close()
+}
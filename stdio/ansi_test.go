@@ -0,0 +1,42 @@
+package stdio
+
+import (
+	"testing"
+
+	"github.com/dddpaul/gonc/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestANSIStripper(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{"plain text", "hello\n", "hello\n"},
+		{"SGR colors", "\x1b[1;31mred\x1b[0m\n", "red\n"},
+		{"cursor movement", "a\x1b[2Ab\x1b[Hc", "abc"},
+		{"two-byte sequence", "a\x1bMb", "ab"},
+		{"OSC title with BEL", "\x1b]0;title\x07prompt$ ", "prompt$ "},
+		{"OSC title with ST", "\x1b]2;title\x1b\\prompt$ ", "prompt$ "},
+		{"ESC inside OSC", "\x1b]0;a\x1bb\x07c", "c"},
+		{"unterminated CSI", "a\x1b[12", "a"},
+		{"binary", "\x00\xff\x1b[m\x80\x7f", "\x00\xff\x80\x7f"},
+		{"UTF-8", "\x1b[32mпривет\x1b[0m", "привет"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every chunk size splits sequences at every possible position
+			for size := 1; size <= len(tt.in); size++ {
+				out := transport.NewMemory(nil)
+				a := newANSIStripper(out)
+				for _, chunk := range transport.Chunks(tt.in, size) {
+					n, err := a.Write([]byte(chunk))
+					assert.NoError(t, err)
+					assert.Equal(t, len(chunk), n)
+				}
+				assert.Equal(t, tt.out, out.Written.String(), "chunk size %d", size)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package stdio
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// OutputBuffer is a size of standard output buffer, zero keeps output unbuffered
+var OutputBuffer int
+
+// FlushInterval limits how long received data may stay in output buffer
+const FlushInterval = 100 * time.Millisecond
+
+// stdout returns standard output buffered according to OutputBuffer
+func stdout() io.WriteCloser {
+	if OutputBuffer == 0 {
+		return os.Stdout
+	}
+	return newBufferedWriter(os.Stdout, OutputBuffer)
+}
+
+// bufferedWriter is flushed every FlushInterval and on Close, so nothing is lost when transfer is over
+type bufferedWriter struct {
+	w    io.WriteCloser
+	buf  *bufio.Writer
+	mu   sync.Mutex
+	done chan struct{}
+	once sync.Once
+}
+
+func newBufferedWriter(w io.WriteCloser, size int) *bufferedWriter {
+	b := &bufferedWriter{w: w, buf: bufio.NewWriterSize(w, size), done: make(chan struct{})}
+	go b.flush()
+	return b
+}
+
+func (b *bufferedWriter) flush() {
+	t := time.NewTicker(FlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			// Write error is sticky, so it's returned by the next Write anyway
+			b.mu.Lock()
+			b.buf.Flush()
+			b.mu.Unlock()
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *bufferedWriter) Close() error {
+	var err error
+	b.once.Do(func() {
+		close(b.done)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		err = b.buf.Flush()
+		if cerr := b.w.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
@@ -0,0 +1,74 @@
+package stdio
+
+import (
+	"io"
+	"sync"
+)
+
+// CancelReader reads from underlying reader in background goroutine, so Close unblocks pending Read at once
+// even if underlying reader can't be interrupted (i.e. standard input attached to terminal).
+type CancelReader struct {
+	r    io.ReadCloser
+	req  chan []byte
+	res  chan result
+	done chan struct{}
+	once sync.Once
+}
+
+type result struct {
+	n   int
+	err error
+}
+
+// NewCancelReader wraps r into CancelReader
+func NewCancelReader(r io.ReadCloser) *CancelReader {
+	c := &CancelReader{
+		r:    r,
+		req:  make(chan []byte),
+		res:  make(chan result),
+		done: make(chan struct{}),
+	}
+	go c.pump()
+	return c
+}
+
+func (c *CancelReader) pump() {
+	for {
+		select {
+		case b := <-c.req:
+			n, err := c.r.Read(b)
+			select {
+			case c.res <- result{n, err}:
+			case <-c.done:
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Read returns io.EOF once reader has been closed
+func (c *CancelReader) Read(b []byte) (int, error) {
+	select {
+	case c.req <- b:
+	case <-c.done:
+		return 0, io.EOF
+	}
+	select {
+	case r := <-c.res:
+		return r.n, r.err
+	case <-c.done:
+		return 0, io.EOF
+	}
+}
+
+// Close unblocks pending Read and closes underlying reader
+func (c *CancelReader) Close() error {
+	var err error
+	c.once.Do(func() {
+		close(c.done)
+		err = c.r.Close()
+	})
+	return err
+}
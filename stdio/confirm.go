@@ -0,0 +1,52 @@
+package stdio
+
+import (
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// ConfirmEOF makes end of terminal input (Ctrl-D) to close connection only if it's repeated within confirmWindow
+// with nothing typed in between, the first one just logs a hint
+var ConfirmEOF bool
+
+// confirmWindow is a period second EOF has to come within
+const confirmWindow = 3 * time.Second
+
+// confirmReader reads terminal again after the first EOF, terminal goes on delivering input after Ctrl-D
+type confirmReader struct {
+	io.ReadCloser
+	first time.Time
+}
+
+// confirmEOF wraps standard input if it's a terminal, other input ends with its first EOF as usual
+func confirmEOF(r io.ReadCloser) io.ReadCloser {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return r
+	}
+	return &confirmReader{ReadCloser: r}
+}
+
+func (c *confirmReader) Read(b []byte) (int, error) {
+	for {
+		n, err := c.ReadCloser.Read(b)
+		if err != io.EOF {
+			// Anything typed in between makes the next EOF the first one again
+			if n > 0 {
+				c.first = time.Time{}
+			}
+			return n, err
+		}
+		if !c.first.IsZero() && time.Since(c.first) < confirmWindow {
+			return n, err
+		}
+		c.first = time.Now()
+		log.Printf("Press Ctrl-D again within %s to disconnect\n", confirmWindow)
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
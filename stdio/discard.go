@@ -0,0 +1,45 @@
+package stdio
+
+import (
+	"bytes"
+	"io"
+)
+
+// DiscardUntil is a pattern received data is discarded until, pattern itself is discarded as well and the rest is written as is
+var DiscardUntil string
+
+// discardWriter drops written data until pattern, pattern spanning several chunks is found as well
+type discardWriter struct {
+	io.WriteCloser
+	pattern []byte
+	window  []byte
+	passed  bool
+}
+
+func newDiscardWriter(w io.WriteCloser) *discardWriter {
+	return &discardWriter{WriteCloser: w, pattern: []byte(DiscardUntil)}
+}
+
+func (d *discardWriter) Write(b []byte) (int, error) {
+	if d.passed {
+		return d.WriteCloser.Write(b)
+	}
+	d.window = append(d.window, b...)
+	i := bytes.Index(d.window, d.pattern)
+	if i < 0 {
+		// Only the tail which may be the start of pattern is kept
+		if keep := len(d.pattern) - 1; len(d.window) > keep {
+			d.window = append(d.window[:0], d.window[len(d.window)-keep:]...)
+		}
+		return len(b), nil
+	}
+	d.passed = true
+	rest := d.window[i+len(d.pattern):]
+	d.window = nil
+	if len(rest) > 0 {
+		if _, err := d.WriteCloser.Write(rest); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
@@ -0,0 +1,42 @@
+package stdio
+
+import (
+	"testing"
+
+	"github.com/dddpaul/gonc/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscardWriter(t *testing.T) {
+	defer func(pattern string) { DiscardUntil = pattern }(DiscardUntil)
+	tests := []struct {
+		name    string
+		pattern string
+		in      string
+		out     string
+	}{
+		{"pattern at start", "login: ", "login: admin\n", "admin\n"},
+		{"pattern in the middle", "login: ", "Welcome\nlogin: admin\n", "admin\n"},
+		{"pattern at end", "$ ", "banner\n$ ", ""},
+		{"no pattern", "login: ", "Welcome\nlog in: ", ""},
+		{"only first pattern is discarded", "> ", "motd> a> b", "a> b"},
+		{"partial match before pattern", "aab", "aaaab-c", "-c"},
+		{"binary", "\x00\xff", "\x01\x00\x00\xff\x80\x00\xff", "\x80\x00\xff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			DiscardUntil = tt.pattern
+			// Every chunk size splits pattern at every possible position
+			for size := 1; size <= len(tt.in); size++ {
+				out := transport.NewMemory(nil)
+				d := newDiscardWriter(out)
+				for _, chunk := range transport.Chunks(tt.in, size) {
+					n, err := d.Write([]byte(chunk))
+					assert.NoError(t, err)
+					assert.Equal(t, len(chunk), n)
+				}
+				assert.Equal(t, tt.out, out.Written.String(), "chunk size %d", size)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package stdio
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Exec is a command which input and output are connected to remote peer instead of standard ones
+var Exec string
+
+// PTY makes Exec command to be run in pseudo-terminal
+var PTY bool
+
+// ExecStderr is where error output of Exec command goes: "local" (standard error), "conn" (remote peer, together with output)
+// or "null" (discarded)
+var ExecStderr = "local"
+
+// CommandTimeout limits how long Exec command runs regardless of connection state, zero means no limit.
+// Command is terminated (and killed if it hasn't exited in a second) once it's exceeded.
+var CommandTimeout time.Duration
+
+// process is a local side backed by child process, child is waited for once both directions have been closed
+type process struct {
+	cmd    *exec.Cmd
+	mu     sync.Mutex
+	closed int
+	exited chan struct{}
+	// output is closed on termination, so it's over even if grandchildren still hold it
+	output io.Closer
+}
+
+// startProcess runs Exec command with env added to environment, its output is a source of data to be sent, received data goes to its input
+func startProcess(env []string) (io.ReadCloser, io.WriteCloser, error) {
+	if strings.TrimSpace(Exec) == "" {
+		return nil, nil, errors.New("Command to execute is empty")
+	}
+	// Command is run by shell, so quoting, pipes and redirections work like in -on-connect hook
+	cmd := exec.Command("sh", "-c", Exec)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", Exec)
+	}
+	p := &process{cmd: cmd, exited: make(chan struct{})}
+	p.cmd.Env = append(os.Environ(), env...)
+
+	var r io.ReadCloser
+	var w io.WriteCloser
+	var err error
+	if PTY {
+		r, w, err = startPTY(p.cmd)
+	} else {
+		r, w, err = startPipes(p.cmd)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Command %q has been started with pid %d\n", Exec, p.cmd.Process.Pid)
+	p.output = r
+	if CommandTimeout > 0 {
+		t := time.AfterFunc(CommandTimeout, p.terminate)
+		go func() {
+			<-p.exited
+			t.Stop()
+		}()
+	}
+	return &processReader{ReadCloser: r, p: p}, &processWriter{WriteCloser: w, p: p}, nil
+}
+
+// addrEnv describes connection addresses by inetd-style REMOTE_ADDR, REMOTE_PORT, LOCAL_ADDR and LOCAL_PORT variables
+func addrEnv(local net.Addr, remote net.Addr) []string {
+	var env []string
+	for _, a := range []struct {
+		prefix string
+		addr   net.Addr
+	}{{"REMOTE", remote}, {"LOCAL", local}} {
+		if a.addr == nil {
+			continue
+		}
+		host, port, err := net.SplitHostPort(a.addr.String())
+		if err != nil {
+			// Named pipes and the like have no port
+			env = append(env, a.prefix+"_ADDR="+a.addr.String())
+			continue
+		}
+		env = append(env, a.prefix+"_ADDR="+host, a.prefix+"_PORT="+port)
+	}
+	return env
+}
+
+func startPipes(cmd *exec.Cmd) (io.ReadCloser, io.WriteCloser, error) {
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ExecStderr == "conn" {
+		return startCombined(cmd, w)
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ExecStderr == "local" {
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return r, w, nil
+}
+
+// startCombined starts cmd with both output and error output written to the same pipe, so they are interleaved as written
+func startCombined(cmd *exec.Cmd, w io.WriteCloser) (io.ReadCloser, io.WriteCloser, error) {
+	r, out, err := os.Pipe()
+	if err != nil {
+		w.Close()
+		return nil, nil, err
+	}
+	cmd.Stdout, cmd.Stderr = out, out
+	err = cmd.Start()
+	// Child has its own copy, output is over once child (and its children) have closed it
+	out.Close()
+	if err != nil {
+		r.Close()
+		w.Close()
+		return nil, nil, err
+	}
+	return r, w, nil
+}
+
+// release waits for child once both directions have been closed, child is killed if it hasn't exited in a second
+func (p *process) release() {
+	p.mu.Lock()
+	p.closed++
+	last := p.closed == 2
+	p.mu.Unlock()
+	if !last {
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.cmd.Wait()
+		close(p.exited)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(time.Second):
+		p.cmd.Process.Kill()
+		err = <-done
+	}
+	if err != nil {
+		log.Printf("Command %q has exited: %s\n", Exec, err)
+	} else {
+		log.Printf("Command %q has exited\n", Exec)
+	}
+}
+
+// terminate asks child to exit, it's killed if it hasn't exited in a second.
+// Connection is closed then as usual since child output is over.
+func (p *process) terminate() {
+	log.Printf("Command %q has exceeded timeout of %s, terminating\n", Exec, CommandTimeout)
+	defer p.output.Close()
+	// Windows doesn't support SIGTERM
+	if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		p.cmd.Process.Kill()
+		return
+	}
+	select {
+	case <-p.exited:
+	case <-time.After(time.Second):
+		log.Printf("Command %q hasn't exited in a second, killing\n", Exec)
+		p.cmd.Process.Kill()
+	}
+}
+
+type processReader struct {
+	io.ReadCloser
+	p    *process
+	once sync.Once
+}
+
+// Read reports EOF instead of error once output has been closed on termination
+func (r *processReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	if errors.Is(err, os.ErrClosed) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *processReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.p.release)
+	return err
+}
+
+type processWriter struct {
+	io.WriteCloser
+	p    *process
+	once sync.Once
+}
+
+func (w *processWriter) Close() error {
+	err := w.WriteCloser.Close()
+	w.once.Do(w.p.release)
+	return err
+}
@@ -0,0 +1,58 @@
+package stdio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+)
+
+// ExitOn is a pattern which ends transfer once it has been received
+var ExitOn string
+
+// ExitOnRegex makes ExitOn to be treated as regular expression
+var ExitOnRegex bool
+
+// ErrExit is returned by destination once ExitOn pattern has been received, chunk containing it is written anyway
+var ErrExit = errors.New("Exit pattern has been received")
+
+// regexWindow is how many bytes of already received data regular expression may span
+const regexWindow = 4096
+
+var exitRegex *regexp.Regexp
+
+// exitWriter looks for pattern in sliding window of written data, so pattern spanning several chunks is found as well
+type exitWriter struct {
+	io.WriteCloser
+	window []byte
+	keep   int
+	match  func([]byte) bool
+}
+
+func newExitWriter(w io.WriteCloser) *exitWriter {
+	e := &exitWriter{WriteCloser: w}
+	if ExitOnRegex {
+		e.keep = regexWindow
+		e.match = exitRegex.Match
+	} else {
+		pattern := []byte(ExitOn)
+		e.keep = len(pattern) - 1
+		e.match = func(b []byte) bool { return bytes.Contains(b, pattern) }
+	}
+	return e
+}
+
+func (e *exitWriter) Write(b []byte) (int, error) {
+	n, err := e.WriteCloser.Write(b)
+	if err != nil {
+		return n, err
+	}
+	e.window = append(e.window, b...)
+	if e.match(e.window) {
+		return n, ErrExit
+	}
+	if len(e.window) > e.keep {
+		e.window = append(e.window[:0], e.window[len(e.window)-e.keep:]...)
+	}
+	return n, nil
+}
@@ -0,0 +1,48 @@
+package stdio
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvExpand makes payload flags to be expanded by Expand
+var EnvExpand bool
+
+// nonce is generated once, so every ${NONCE} of the run gets the same value
+var nonce string
+
+// Expand replaces ${VAR} and $VAR with environment variables, besides them ${NONCE} is 16 random hex digits
+// (the same for the whole run), ${TIMESTAMP} is Unix time in seconds, ${TIMESTAMP_MS} is Unix time in milliseconds
+// and $$ is a literal dollar sign. Unset variables are expanded to empty string.
+func Expand(s string) string {
+	return os.Expand(s, value)
+}
+
+// ExpandHex is Expand of hex string, every value is inserted hex encoded, so it's sent as text once string is decoded
+func ExpandHex(s string) string {
+	return os.Expand(s, func(name string) string {
+		return hex.EncodeToString([]byte(value(name)))
+	})
+}
+
+func value(name string) string {
+	switch name {
+	case "$":
+		return "$"
+	case "NONCE":
+		if nonce == "" {
+			b := make([]byte, 8)
+			rand.Read(b)
+			nonce = hex.EncodeToString(b)
+		}
+		return nonce
+	case "TIMESTAMP":
+		return strconv.FormatInt(time.Now().Unix(), 10)
+	case "TIMESTAMP_MS":
+		return strconv.FormatInt(time.Now().UnixMilli(), 10)
+	}
+	return os.Getenv(name)
+}
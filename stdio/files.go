@@ -0,0 +1,105 @@
+package stdio
+
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// FileList is a flag value which takes comma separated paths and may be repeated
+type FileList []string
+
+func (l *FileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set appends comma separated paths
+func (l *FileList) Set(value string) error {
+	for _, path := range strings.Split(value, ",") {
+		if path != "" {
+			*l = append(*l, path)
+		}
+	}
+	return nil
+}
+
+// SendFiles are sent one by one in given order instead of standard input
+var SendFiles FileList
+
+// SendDelay is a pause between SendFiles
+var SendDelay time.Duration
+
+// SendSize returns total size of SendFiles, it's zero if there are none or any of them is not a regular file
+func SendSize() int64 {
+	var size int64
+	for _, path := range SendFiles {
+		fi, err := os.Stat(path)
+		if err != nil || !fi.Mode().IsRegular() {
+			return 0
+		}
+		size += fi.Size()
+	}
+	return size
+}
+
+// filesReader concatenates files, every one is reported once it has been read up to EOF
+type filesReader struct {
+	io.Reader
+	files []*sentFile
+}
+
+type sentFile struct {
+	*os.File
+	bytes uint64
+	// next is a file to be read after this one, total is reported after the last one
+	next  *sentFile
+	total *uint64
+}
+
+// openFiles opens all the files at once, so missing one is reported before anything is sent
+func openFiles(paths []string) (*filesReader, error) {
+	r := &filesReader{}
+	var total uint64
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.files = append(r.files, &sentFile{File: f, total: &total})
+	}
+	readers := make([]io.Reader, len(r.files))
+	for i, f := range r.files {
+		if i+1 < len(r.files) {
+			f.next = r.files[i+1]
+		}
+		readers[i] = f
+	}
+	r.Reader = io.MultiReader(readers...)
+	return r, nil
+}
+
+func (r *filesReader) Close() error {
+	var closers []io.Closer
+	for _, f := range r.files {
+		closers = append(closers, f)
+	}
+	return closeAll(closers)
+}
+
+func (f *sentFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.bytes += uint64(n)
+	*f.total += uint64(n)
+	if err == io.EOF {
+		log.Printf("File %s has been read, %d bytes\n", f.Name(), f.bytes)
+		if f.next == nil {
+			log.Printf("All files have been read, %d bytes total\n", *f.total)
+		} else if SendDelay > 0 {
+			time.Sleep(SendDelay)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,46 @@
+package stdio
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strconv"
+)
+
+// Format is a name of rendering from Formats applied to received data printed to standard output
+var Format = "raw"
+
+// FormatBoth makes sent data to be printed to standard output rendered by Format as well,
+// received chunks are prefixed by "< " and sent ones by "> " then
+var FormatBoth bool
+
+// Formats are available renderings of binary data, every one takes a chunk of data (datagram in UDP mode)
+var Formats = map[string]func([]byte) []byte{
+	"raw":     func(b []byte) []byte { return b },
+	"hex":     func(b []byte) []byte { return []byte(hex.EncodeToString(b) + "\n") },
+	"hexdump": func(b []byte) []byte { return []byte(hex.Dump(b)) },
+	"base64":  func(b []byte) []byte { return []byte(base64.StdEncoding.EncodeToString(b) + "\n") },
+	"escaped": func(b []byte) []byte { return []byte(strconv.Quote(string(b)) + "\n") },
+}
+
+// format renders received data written to out, sent data read from in is rendered to out as well if FormatBoth is set
+func format(in io.ReadCloser, out io.WriteCloser) (io.ReadCloser, io.WriteCloser) {
+	if Format == "raw" {
+		return in, out
+	}
+	render := Formats[Format]
+	if !FormatBoth {
+		return in, &transformWriter{w: out, transform: render}
+	}
+	if in != nil {
+		sent := &transformWriter{w: out, transform: prefixed("> ", render)}
+		in = &teeReader{Reader: io.TeeReader(in, sent), closers: []io.Closer{in}}
+	}
+	return in, &transformWriter{w: out, transform: prefixed("< ", render)}
+}
+
+func prefixed(prefix string, render func([]byte) []byte) func([]byte) []byte {
+	return func(b []byte) []byte {
+		return append([]byte(prefix), render(b)...)
+	}
+}
@@ -0,0 +1,106 @@
+package stdio
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+	"unicode"
+)
+
+// HexSend makes data to be sent to be decoded from hex lines, whitespace is ignored and lines starting with # are skipped
+var HexSend bool
+
+// HexSkipInvalid makes invalid hex lines to be logged and skipped instead of stopping input with error
+var HexSkipInvalid bool
+
+// invalidInput is set once input has been stopped by invalid data
+var invalidInput atomic.Bool
+
+// InvalidInput reports whether input has been stopped by invalid data, exit status is non-zero then
+func InvalidInput() bool {
+	return invalidInput.Load()
+}
+
+// hexReader decodes hex lines and returns their bytes, one line per Read unless buffer is too small.
+// Invalid line stops input with error unless HexSkipInvalid is set. Long line is decoded part by part, odd digit at the end of a part is kept for the next one.
+type hexReader struct {
+	r       io.ReadCloser
+	s       *lineScanner
+	line    int
+	pending []byte
+	// continued is set while parts of long line are read, odd is a digit left from the previous part
+	continued bool
+	odd       []byte
+	// skip is set if the rest of long line is skipped as comment or invalid one
+	skip bool
+}
+
+func newHexReader(r io.ReadCloser) *hexReader {
+	return &hexReader{r: r, s: newLineScanner(r)}
+}
+
+func (h *hexReader) Read(b []byte) (int, error) {
+	for len(h.pending) == 0 {
+		if !h.s.Scan() {
+			if err := h.s.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		continued := h.continued
+		h.continued = h.s.partial
+		if !continued {
+			h.line++
+			h.odd, h.skip = nil, false
+			if h.s.partial {
+				log.Printf("WARNING: Line %d exceeds %d bytes, it's decoded in parts\n", h.line, MaxLineLength)
+			}
+		}
+		if h.skip {
+			continue
+		}
+		line := bytes.TrimSpace(h.s.Bytes())
+		if !continued && bytes.HasPrefix(line, []byte("#")) {
+			h.skip = true
+			continue
+		}
+		digits := append(h.odd, bytes.Map(dropSpace, line)...)
+		h.odd = nil
+		if h.s.partial && len(digits)%2 == 1 {
+			h.odd = []byte{digits[len(digits)-1]}
+			digits = digits[:len(digits)-1]
+		}
+		data, err := hex.DecodeString(string(digits))
+		if err != nil && !HexSkipInvalid {
+			invalidInput.Store(true)
+			return 0, fmt.Errorf("Invalid hex on line %d, input has been stopped: %s", h.line, err)
+		}
+		if err != nil {
+			if continued || h.s.partial {
+				log.Printf("ERROR: Invalid hex on line %d, the rest of it has been skipped: %s\n", h.line, err)
+			} else {
+				log.Printf("ERROR: Invalid hex on line %d has been skipped: %s\n", h.line, err)
+			}
+			h.skip = true
+			continue
+		}
+		h.pending = data
+	}
+	n := copy(b, h.pending)
+	h.pending = h.pending[n:]
+	return n, nil
+}
+
+func (h *hexReader) Close() error {
+	return h.r.Close()
+}
+
+func dropSpace(r rune) rune {
+	if unicode.IsSpace(r) {
+		return -1
+	}
+	return r
+}
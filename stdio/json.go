@@ -0,0 +1,81 @@
+package stdio
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+)
+
+// Message is a JSON line representation of transferred data
+type Message struct {
+	Dir  string    `json:"dir"`
+	TS   time.Time `json:"ts"`
+	Data []byte    `json:"data_b64"`
+}
+
+// jsonWriter emits every written chunk as a JSON line
+type jsonWriter struct {
+	w   io.WriteCloser
+	enc *json.Encoder
+}
+
+func newJSONWriter(w io.WriteCloser) *jsonWriter {
+	return &jsonWriter{w: w, enc: json.NewEncoder(w)}
+}
+
+func (j *jsonWriter) Write(b []byte) (int, error) {
+	if err := j.enc.Encode(Message{Dir: "recv", TS: time.Now(), Data: b}); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (j *jsonWriter) Close() error {
+	return j.w.Close()
+}
+
+// jsonReader decodes JSON lines and returns their data, one line per Read unless buffer is too small.
+// Malformed lines are logged and skipped, so are lines exceeding MaxLineLength as their parts can't be decoded.
+type jsonReader struct {
+	r       io.ReadCloser
+	s       *lineScanner
+	pending []byte
+	// long is set while the rest of long line is skipped
+	long bool
+}
+
+func newJSONReader(r io.ReadCloser) *jsonReader {
+	return &jsonReader{r: r, s: newLineScanner(r)}
+}
+
+func (j *jsonReader) Read(b []byte) (int, error) {
+	for len(j.pending) == 0 {
+		if !j.s.Scan() {
+			if err := j.s.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		if j.s.partial || j.long {
+			if !j.long {
+				log.Printf("ERROR: JSON line exceeding %d bytes has been skipped\n", MaxLineLength)
+			}
+			j.long = j.s.partial
+			continue
+		}
+		var m Message
+		if err := json.Unmarshal(j.s.Bytes(), &m); err != nil {
+			log.Printf("ERROR: Malformed JSON line has been skipped: %s\n", err)
+			continue
+		}
+		j.pending = m.Data
+	}
+	n := copy(b, j.pending)
+	j.pending = j.pending[n:]
+	return n, nil
+}
+
+func (j *jsonReader) Close() error {
+	return j.r.Close()
+}
@@ -0,0 +1,39 @@
+package stdio
+
+import (
+	"bufio"
+	"io"
+)
+
+// MaxLineLength limits line buffered by line-oriented input (hex and JSON lines), longer line is processed in parts of this size
+var MaxLineLength = 1 << 20
+
+// lineScanner is a scanner of lines which never buffers more than MaxLineLength bytes,
+// longer line is returned in parts, so input without newlines can't exhaust memory
+type lineScanner struct {
+	*bufio.Scanner
+	// partial is set if the last token is a part of long line which goes on in the next one
+	partial bool
+}
+
+func newLineScanner(r io.Reader) *lineScanner {
+	l := &lineScanner{Scanner: bufio.NewScanner(r)}
+	size := bufio.MaxScanTokenSize
+	if size > MaxLineLength {
+		size = MaxLineLength
+	}
+	// Line of MaxLineLength bytes fits along with its line ending
+	l.Buffer(make([]byte, 0, size), MaxLineLength+2)
+	l.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if err == nil && (len(token) > MaxLineLength || advance == 0 && len(data) >= MaxLineLength+2) {
+			l.partial = true
+			return MaxLineLength, data[:MaxLineLength], nil
+		}
+		if advance > 0 {
+			l.partial = false
+		}
+		return advance, token, err
+	})
+	return l
+}
@@ -0,0 +1,101 @@
+package stdio
+
+import (
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineScanner(t *testing.T) {
+	defer func(max int) { MaxLineLength = max }(MaxLineLength)
+	MaxLineLength = 4
+	tests := []struct {
+		name    string
+		in      string
+		tokens  []string
+		partial []bool
+	}{
+		{"short lines", "ab\ncd\r\n", []string{"ab", "cd"}, []bool{false, false}},
+		{"exact length", "abcd\n", []string{"abcd"}, []bool{false}},
+		{"exact length with CRLF", "abcd\r\nef\n", []string{"abcd", "ef"}, []bool{false, false}},
+		{"one byte longer", "abcde\n", []string{"abcd", "e"}, []bool{true, false}},
+		{"long line", "abcdefghij\nk\n", []string{"abcd", "efgh", "ij", "k"}, []bool{true, true, false, false}},
+		{"no newline", "abcdefgh", []string{"abcd", "efgh"}, []bool{true, false}},
+		{"binary", "\x00\xff\x01\xfe\x02\n", []string{"\x00\xff\x01\xfe", "\x02"}, []bool{true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Data comes byte by byte, so every line boundary falls between reads
+			s := newLineScanner(iotest.OneByteReader(strings.NewReader(tt.in)))
+			var tokens []string
+			var partial []bool
+			for s.Scan() {
+				tokens = append(tokens, s.Text())
+				partial = append(partial, s.partial)
+			}
+			assert.NoError(t, s.Err())
+			assert.Equal(t, tt.tokens, tokens)
+			assert.Equal(t, tt.partial, partial)
+		})
+	}
+}
+
+func TestHexReader(t *testing.T) {
+	defer func(max int, skip bool) { MaxLineLength, HexSkipInvalid = max, skip }(MaxLineLength, HexSkipInvalid)
+	tests := []struct {
+		name    string
+		max     int
+		skip    bool
+		in      string
+		out     string
+		invalid bool
+	}{
+		{"lines", 1 << 20, false, "68 65\n6c6c6f\n", "hello", false},
+		{"comments and empty lines", 1 << 20, false, "# greeting\n\n6869\n  # indented\n", "hi", false},
+		{"invalid line", 1 << 20, true, "6869\nzz\n0a\n", "hi\n", false},
+		{"odd line", 1 << 20, true, "6869\n686\n0a\n", "hi\n", false},
+		{"invalid line stops input", 1 << 20, false, "6869\nzz\n0a\n", "hi", true},
+		{"odd line stops input", 1 << 20, false, "6869\n686\n0a\n", "hi", true},
+		{"binary", 1 << 20, false, "00ff7f80\n", "\x00\xff\x7f\x80", false},
+		// Parts of 3 bytes split every digit pair
+		{"long line", 3, false, "68656c6c6f\n0a\n", "hello\n", false},
+		{"long line with spaces", 3, false, "68 65 6c 6c 6f\n", "hello", false},
+		{"long comment", 3, false, "# 6869\n6f6b\n", "ok", false},
+		// Parts before invalid one have been sent already
+		{"long invalid line", 3, true, "6869zz6869\n6f6b\n", "h" + "ok", false},
+		{"long odd line", 3, true, "68696\n6f6b\n", "h" + "ok", false},
+		{"long invalid line stops input", 3, false, "6869zz6869\n6f6b\n", "h", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			MaxLineLength, HexSkipInvalid = tt.max, tt.skip
+			invalidInput.Store(false)
+			r := newHexReader(io.NopCloser(iotest.OneByteReader(strings.NewReader(tt.in))))
+			out, err := io.ReadAll(r)
+			if tt.invalid {
+				assert.ErrorContains(t, err, "Invalid hex")
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.invalid, InvalidInput())
+			assert.Equal(t, tt.out, string(out))
+		})
+	}
+	invalidInput.Store(false)
+}
+
+func TestJSONReaderLongLine(t *testing.T) {
+	defer func(max int) { MaxLineLength = max }(MaxLineLength)
+	line := func(data string) string {
+		return `{"dir":"send","data_b64":"` + base64.StdEncoding.EncodeToString([]byte(data)) + "\"}\n"
+	}
+	MaxLineLength = len(line("one"))
+	r := newJSONReader(io.NopCloser(strings.NewReader(line("one") + line("long one") + "not JSON\n" + line("two"))))
+	out, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "onetwo", string(out))
+}
@@ -0,0 +1,85 @@
+package stdio
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// LogFile is a file log is appended to instead of standard error
+var LogFile string
+
+// LogMaxSize is a size in bytes log file is rotated at (the previous one is kept with .1 suffix), zero means no rotation
+var LogMaxSize int64
+
+// OpenLog redirects log to LogFile, it does nothing if LogFile isn't set
+func OpenLog() error {
+	if LogFile == "" {
+		return nil
+	}
+	if LogMaxSize < 0 {
+		return fmt.Errorf("Log file size %d is negative", LogMaxSize)
+	}
+	l := &logFile{path: LogFile, max: LogMaxSize}
+	if err := l.open(l.path); err != nil {
+		return fmt.Errorf("Unable to open log file: %s", err)
+	}
+	log.SetOutput(l)
+	return nil
+}
+
+// logFile appends to file and rotates it once it has grown over max bytes
+type logFile struct {
+	sync.Mutex
+	path string
+	max  int64
+	f    *os.File
+	size int64
+}
+
+func (l *logFile) open(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+func (l *logFile) Write(b []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.max > 0 && l.size > 0 && l.size+int64(len(b)) > l.max {
+		l.rotate()
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file to .1 and starts a new one, logging goes on to the current file if rotation has failed
+func (l *logFile) rotate() {
+	old := l.f
+	if os.Rename(l.path, l.path+".1") == nil {
+		// The current file is closed only once the new one has been opened
+		if l.open(l.path) == nil {
+			old.Close()
+		}
+		return
+	}
+	// Open file can't be renamed on Windows, so it's closed for renaming and reopened wherever it is if new one can't be opened
+	old.Close()
+	path := l.path
+	if os.Rename(l.path, l.path+".1") == nil {
+		path = l.path + ".1"
+	}
+	if l.open(l.path) != nil {
+		l.open(path)
+	}
+}
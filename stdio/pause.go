@@ -0,0 +1,58 @@
+package stdio
+
+import (
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// Pausable makes output of received data to be paused and resumed by signal (SIGUSR2), connection isn't read meanwhile,
+// so remote peer is slowed down by flow control
+var Pausable bool
+
+var pause struct {
+	sync.Mutex
+	cond   *sync.Cond
+	paused bool
+	once   sync.Once
+}
+
+// startPause toggles pause on every signal
+func startPause() {
+	pause.cond = sync.NewCond(&pause.Mutex)
+	c := make(chan os.Signal, 1)
+	notifyPause(c)
+	go func() {
+		for range c {
+			pause.Lock()
+			pause.paused = !pause.paused
+			if pause.paused {
+				log.Println("Output has been paused, send SIGUSR2 again to resume")
+			} else {
+				log.Println("Output has been resumed")
+				pause.cond.Broadcast()
+			}
+			pause.Unlock()
+		}
+	}()
+}
+
+// pauseWriter blocks writes while output is paused
+type pauseWriter struct {
+	io.WriteCloser
+}
+
+func newPauseWriter(w io.WriteCloser) *pauseWriter {
+	pause.once.Do(startPause)
+	return &pauseWriter{w}
+}
+
+func (p *pauseWriter) Write(b []byte) (int, error) {
+	pause.Lock()
+	for pause.paused {
+		pause.cond.Wait()
+	}
+	pause.Unlock()
+	return p.WriteCloser.Write(b)
+}
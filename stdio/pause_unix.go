@@ -0,0 +1,13 @@
+//go:build !windows
+
+package stdio
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func notifyPause(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR2)
+}
@@ -0,0 +1,6 @@
+package stdio
+
+import "os"
+
+// notifyPause does nothing, there is no SIGUSR2 on Windows
+func notifyPause(c chan<- os.Signal) {}
@@ -0,0 +1,21 @@
+package stdio
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+)
+
+// ReadPayload returns contents of file or decoded hex string (whitespace is ignored), nil if both are empty
+func ReadPayload(file string, hexString string) ([]byte, error) {
+	switch {
+	case file != "" && hexString != "":
+		return nil, errors.New("Payload can be taken either from file or hex string")
+	case file != "":
+		return os.ReadFile(file)
+	case hexString != "":
+		return hex.DecodeString(strings.Join(strings.Fields(hexString), ""))
+	}
+	return nil, nil
+}
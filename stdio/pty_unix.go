@@ -0,0 +1,35 @@
+//go:build !windows
+
+package stdio
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+// startPTY runs cmd in pseudo-terminal of default 80x24 size, both directions are served by terminal master
+func startPTY(cmd *exec.Cmd) (io.ReadCloser, io.WriteCloser, error) {
+	master, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: 24, Cols: 80})
+	if err != nil {
+		return nil, nil, err
+	}
+	return ptyReader{master}, master, nil
+}
+
+// ptyReader reports EOF instead of EIO which is returned by terminal master once child has gone
+type ptyReader struct {
+	*os.File
+}
+
+func (r ptyReader) Read(b []byte) (int, error) {
+	n, err := r.File.Read(b)
+	if errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,12 @@
+package stdio
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+)
+
+// startPTY is not available, pseudo-terminals are supported on Unix only
+func startPTY(cmd *exec.Cmd) (io.ReadCloser, io.WriteCloser, error) {
+	return nil, nil, errors.New("Pseudo-terminal is supported on Unix only")
+}
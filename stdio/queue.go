@@ -0,0 +1,79 @@
+package stdio
+
+import (
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// BufferQueue is a number of received chunks queued between connection and output, zero writes them directly
+var BufferQueue int
+
+// Overflow defines what happens once queue is full: "block" waits for output, "drop" drops the oldest queued chunk
+var Overflow = "block"
+
+// queueWriter writes chunks to w in its own goroutine, so slow output doesn't hold connection unless queue is full
+type queueWriter struct {
+	w       io.WriteCloser
+	chunks  chan []byte
+	drop    bool
+	mu      sync.Mutex
+	done    chan struct{}
+	err     atomic.Value
+	dropped uint64
+}
+
+func newQueueWriter(w io.WriteCloser, size int, drop bool) *queueWriter {
+	q := &queueWriter{w: w, chunks: make(chan []byte, size), drop: drop, done: make(chan struct{})}
+	go q.flush()
+	return q
+}
+
+func (q *queueWriter) flush() {
+	defer close(q.done)
+	for b := range q.chunks {
+		if _, err := q.w.Write(b); err != nil {
+			q.err.Store(err)
+			// Drain the rest, so writers aren't blocked
+			for range q.chunks {
+			}
+			return
+		}
+	}
+}
+
+func (q *queueWriter) Write(b []byte) (int, error) {
+	if err, ok := q.err.Load().(error); ok {
+		return 0, err
+	}
+	chunk := append([]byte(nil), b...)
+	if !q.drop {
+		q.chunks <- chunk
+		return len(b), nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		select {
+		case q.chunks <- chunk:
+			return len(b), nil
+		default:
+		}
+		// Stale data is worthless, the oldest chunk gives its place up
+		select {
+		case <-q.chunks:
+			q.dropped++
+		default:
+		}
+	}
+}
+
+func (q *queueWriter) Close() error {
+	close(q.chunks)
+	<-q.done
+	if q.dropped > 0 {
+		log.Printf("%d received chunks have been dropped on queue overflow\n", q.dropped)
+	}
+	return q.w.Close()
+}
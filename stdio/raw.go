@@ -0,0 +1,81 @@
+package stdio
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// RawStdin switches terminal attached to standard input into raw mode for the duration of session,
+// so every keystroke is sent at once and remote peer controls echo
+var RawStdin bool
+
+var raw struct {
+	sync.Mutex
+	state *term.State
+	log   io.Writer
+}
+
+// makeRaw puts standard input terminal into raw mode, it does nothing if standard input isn't a terminal
+func makeRaw() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil
+	}
+	raw.Lock()
+	defer raw.Unlock()
+	if raw.state != nil {
+		return nil
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	raw.state = state
+	// Terminal doesn't turn "\n" into "\r\n" anymore, log file is left as is
+	raw.log = log.Writer()
+	if raw.log == io.Writer(os.Stderr) {
+		log.SetOutput(crlfWriter{raw.log})
+	}
+
+	// Terminal must be restored even if gonc is killed
+	OnSignal(Restore)
+	return nil
+}
+
+// Restore returns terminal into the state it had before raw mode, it's safe to call it many times
+func Restore() {
+	raw.Lock()
+	defer raw.Unlock()
+	if raw.state == nil {
+		return
+	}
+	term.Restore(int(os.Stdin.Fd()), raw.state)
+	log.SetOutput(raw.log)
+	raw.state = nil
+}
+
+// rawReader restores terminal once it's closed
+type rawReader struct {
+	*os.File
+}
+
+func (r rawReader) Close() error {
+	Restore()
+	return r.File.Close()
+}
+
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(b []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
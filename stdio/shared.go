@@ -0,0 +1,33 @@
+package stdio
+
+import (
+	"io"
+	"sync"
+)
+
+// SharedWriter serializes writes of several sessions to the same output, which stays open until all of them are over
+type SharedWriter struct {
+	w  io.WriteCloser
+	mu sync.Mutex
+}
+
+// NewSharedWriter makes w to be shared by several sessions
+func NewSharedWriter(w io.WriteCloser) *SharedWriter {
+	return &SharedWriter{w: w}
+}
+
+func (s *SharedWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(b)
+}
+
+// Close does nothing as other sessions may still write, Release closes the output
+func (s *SharedWriter) Close() error {
+	return nil
+}
+
+// Release closes the output once all sessions are over
+func (s *SharedWriter) Release() error {
+	return s.w.Close()
+}
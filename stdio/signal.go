@@ -0,0 +1,35 @@
+package stdio
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var cleanups struct {
+	sync.Mutex
+	funcs []func()
+	once  sync.Once
+}
+
+// OnSignal registers f to be called if gonc is interrupted or terminated, process exits once all registered functions are done
+func OnSignal(f func()) {
+	cleanups.Lock()
+	cleanups.funcs = append(cleanups.funcs, f)
+	cleanups.Unlock()
+	cleanups.once.Do(func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		go func() {
+			<-c
+			cleanups.Lock()
+			funcs := cleanups.funcs
+			cleanups.Unlock()
+			for i := len(funcs) - 1; i >= 0; i-- {
+				funcs[i]()
+			}
+			os.Exit(1)
+		}()
+	})
+}
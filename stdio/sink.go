@@ -0,0 +1,64 @@
+package stdio
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SinkInterval is a period rolling throughput is logged with in sink mode
+const SinkInterval = time.Second
+
+// sink discards received data and logs throughput every SinkInterval and in total once it's closed
+type sink struct {
+	started time.Time
+	bytes   uint64
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newSink() *sink {
+	s := &sink{started: time.Now(), done: make(chan struct{})}
+	go s.report()
+	return s
+}
+
+func (s *sink) report() {
+	t := time.NewTicker(SinkInterval)
+	defer t.Stop()
+	var last uint64
+	for {
+		select {
+		case <-t.C:
+			bytes := atomic.LoadUint64(&s.bytes)
+			log.Printf("Sink: %d bytes received, %s over the last %s\n", bytes, rate(bytes-last, SinkInterval), SinkInterval)
+			last = bytes
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *sink) Write(b []byte) (int, error) {
+	atomic.AddUint64(&s.bytes, uint64(len(b)))
+	return len(b), nil
+}
+
+func (s *sink) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+		elapsed := time.Since(s.started)
+		bytes := atomic.LoadUint64(&s.bytes)
+		log.Printf("Sink: %d bytes received in %s, %s\n", bytes, elapsed, rate(bytes, elapsed))
+	})
+	return nil
+}
+
+func rate(bytes uint64, elapsed time.Duration) string {
+	if elapsed <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f MB/s", float64(bytes)/elapsed.Seconds()/1e6)
+}
@@ -0,0 +1,82 @@
+package stdio
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// SplitOutput is a prefix of files received data is written to instead of standard output: prefix.000, prefix.001 and so on
+var SplitOutput string
+
+// SplitSize is a size in bytes the next file is started at, zero means no limit
+var SplitSize int64
+
+// SplitInterval is a period the next file is started after, zero means no limit
+var SplitInterval time.Duration
+
+// splitIndex is the number of the next file, it's shared by sessions, so every one goes on with numbering
+var splitIndex struct {
+	sync.Mutex
+	next int
+}
+
+// splitWriter writes to the current file until it's full (data is split at exactly SplitSize) or expired,
+// every file is created once there is data for it and closed on rotation
+type splitWriter struct {
+	f       *os.File
+	size    int64
+	started time.Time
+}
+
+func (s *splitWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		if s.f != nil && (SplitSize > 0 && s.size >= SplitSize || SplitInterval > 0 && time.Since(s.started) >= SplitInterval) {
+			if err := s.f.Close(); err != nil {
+				return written, err
+			}
+			s.f = nil
+		}
+		if s.f == nil {
+			if err := s.open(); err != nil {
+				return written, err
+			}
+		}
+		chunk := b
+		if SplitSize > 0 && int64(len(chunk)) > SplitSize-s.size {
+			chunk = chunk[:SplitSize-s.size]
+		}
+		n, err := s.f.Write(chunk)
+		written += n
+		s.size += int64(n)
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+func (s *splitWriter) open() error {
+	splitIndex.Lock()
+	path := fmt.Sprintf("%s.%03d", SplitOutput, splitIndex.next)
+	splitIndex.next++
+	splitIndex.Unlock()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	log.Printf("Received data is written to %s\n", path)
+	s.f, s.size, s.started = f, 0, time.Now()
+	return nil
+}
+
+func (s *splitWriter) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
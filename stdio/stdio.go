@@ -0,0 +1,279 @@
+package stdio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"runtime"
+)
+
+// Mode defines local side of transfer: "stdio" uses standard input and output,
+// "reflect" sends received data back to remote peer transformed by ReflectTransform,
+// "sink" discards received data and logs throughput, nothing is sent
+var Mode = "stdio"
+
+// ReflectTransform is a name of transformation from Transforms applied to data in reflect mode
+var ReflectTransform = "none"
+
+// JSONLines makes received data to be emitted as JSON lines and data to be sent to be decoded from JSON lines
+var JSONLines bool
+
+// NoStdin disables standard input, nothing is sent to remote peer and connection is kept until remote peer closes it
+var NoStdin bool
+
+// Transforms are available reflect mode transformations, every one takes a chunk of received data (datagram in UDP mode).
+// They work on bytes: case changes and rot13 touch ASCII letters only, so other bytes pass as is,
+// reverse reverses bytes, so multibyte UTF-8 characters don't survive it.
+var Transforms = map[string]func([]byte) []byte{
+	"none":    func(b []byte) []byte { return b },
+	"upper":   mapASCII(func(c byte) byte { return c - 'a' + 'A' }, nil),
+	"lower":   mapASCII(nil, func(c byte) byte { return c - 'A' + 'a' }),
+	"reverse": reverse,
+	"rot13":   rot13,
+}
+
+// Check validates local side settings
+func Check() error {
+	switch Mode {
+	case "stdio", "reflect", "sink":
+	default:
+		return fmt.Errorf("Unknown mode %q", Mode)
+	}
+	if _, ok := Transforms[ReflectTransform]; !ok {
+		return fmt.Errorf("Unknown reflect transform %q", ReflectTransform)
+	}
+	if Exec != "" && Mode != "stdio" {
+		return fmt.Errorf("Command can't be executed in %s mode", Mode)
+	}
+	if NoStdin && (Exec != "" || Mode != "stdio") {
+		return errors.New("Standard input can be disabled in stdio mode only")
+	}
+	if _, ok := Formats[Format]; !ok {
+		return fmt.Errorf("Unknown format %q", Format)
+	}
+	if Format != "raw" && (JSONLines || Exec != "" || Mode != "stdio") {
+		return errors.New("Format can't be combined with JSON lines, command execution or reflect mode")
+	}
+	if FormatBoth && Format == "raw" {
+		return errors.New("Rendering of sent data requires format other than raw")
+	}
+	if HexSend && (JSONLines || Exec != "" || Mode != "stdio") {
+		return errors.New("Hex input can't be combined with JSON lines, command execution or reflect mode")
+	}
+	if len(SendFiles) > 0 && (NoStdin || RawStdin || Exec != "" || Mode != "stdio") {
+		return errors.New("Files can be sent in stdio mode only, instead of standard input")
+	}
+	if StripANSI && (Exec != "" || Mode != "stdio") {
+		return errors.New("Escape sequences can be stripped in stdio mode only")
+	}
+	if Tail < 0 {
+		return fmt.Errorf("Tail size %d is negative", Tail)
+	}
+	if Tail > 0 && (Format != "raw" || JSONLines || Exec != "" || Mode != "stdio") {
+		return errors.New("Tail can't be combined with format, JSON lines, command execution or reflect mode")
+	}
+	if ExitOnRegex {
+		re, err := regexp.Compile(ExitOn)
+		if err != nil {
+			return fmt.Errorf("Invalid exit pattern: %s", err)
+		}
+		exitRegex = re
+	}
+	if SendURL != "" && (len(SendFiles) > 0 || NoStdin || RawStdin || Exec != "" || Mode != "stdio") {
+		return errors.New("URL can be sent in stdio mode only, instead of standard input and files")
+	}
+	if TeeHex && (Format != "raw" || JSONLines || Exec != "" || Mode != "stdio") {
+		return errors.New("Hexdump can't be combined with format, JSON lines, command execution or reflect mode")
+	}
+	if DiscardUntil != "" && (Exec != "" || Mode != "stdio") {
+		return errors.New("Received data can be discarded until pattern in stdio mode only")
+	}
+	if ConfirmEOF && (NoStdin || RawStdin || len(SendFiles) > 0 || SendURL != "" || Exec != "" || Mode != "stdio") {
+		return errors.New("EOF confirmation requires standard input in stdio mode, raw terminal has no EOF")
+	}
+	if SplitSize < 0 || SplitInterval < 0 {
+		return errors.New("Split size and interval can't be negative")
+	}
+	if (SplitSize > 0 || SplitInterval > 0) && SplitOutput == "" {
+		return errors.New("Split size and interval require output files prefix")
+	}
+	if SplitOutput != "" && (OutputBuffer > 0 || Exec != "" || Mode != "stdio") {
+		return errors.New("Output can be split into files in stdio mode only, files are written unbuffered")
+	}
+	if MaxLineLength <= 0 {
+		return fmt.Errorf("Maximum line length %d isn't positive", MaxLineLength)
+	}
+	if OutputBuffer < 0 {
+		return fmt.Errorf("Output buffer size %d is negative", OutputBuffer)
+	}
+	if Overflow != "block" && Overflow != "drop" {
+		return fmt.Errorf("Unknown overflow policy %q", Overflow)
+	}
+	if BufferQueue < 0 {
+		return fmt.Errorf("Queue size %d is negative", BufferQueue)
+	}
+	if BufferQueue > 0 && (Exec != "" || Mode != "stdio") {
+		return errors.New("Queue can be used in stdio mode only")
+	}
+	if Pausable && runtime.GOOS == "windows" {
+		return errors.New("Pause is supported on Unix only")
+	}
+	if Pausable && (Exec != "" || Mode != "stdio") {
+		return errors.New("Output can be paused in stdio mode only")
+	}
+	switch ExecStderr {
+	case "local", "conn", "null":
+	default:
+		return fmt.Errorf("Unknown command error output destination %q", ExecStderr)
+	}
+	if ExecStderr != "local" && (Exec == "" || PTY) {
+		return errors.New("Command error output can be redirected for command executed without pseudo-terminal only")
+	}
+	if PTY && Exec == "" {
+		return errors.New("Pseudo-terminal requires command to execute")
+	}
+	return nil
+}
+
+// Streams returns local side of transfer: source of data to be sent (nil if NoStdin is set) and destination for received data.
+// Connection addresses are passed to executed command, nil ones (i.e. remote one of UDP listener) are skipped.
+func Streams(local net.Addr, remote net.Addr) (io.ReadCloser, io.WriteCloser, error) {
+	in, out, err := streams(local, remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	if in, out, err = tee(in, out); err != nil {
+		return nil, nil, err
+	}
+	if Transcript != "" {
+		if in, out, err = transcript(in, out); err != nil {
+			return nil, nil, err
+		}
+	}
+	if ExitOn != "" {
+		out = newExitWriter(out)
+	}
+	return in, out, nil
+}
+
+func streams(local net.Addr, remote net.Addr) (io.ReadCloser, io.WriteCloser, error) {
+	if Mode == "reflect" {
+		// Everything written to destination comes back from source
+		r, w := io.Pipe()
+		return r, &transformWriter{w: w, transform: Transforms[ReflectTransform]}, nil
+	}
+	if Mode == "sink" {
+		return nil, newSink(), nil
+	}
+	if Exec != "" {
+		return startProcess(addrEnv(local, remote))
+	}
+	var in io.ReadCloser = os.Stdin
+	if NoStdin {
+		in = nil
+	} else if SendURL != "" {
+		body, err := fetch(SendURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		in = body
+	} else if len(SendFiles) > 0 {
+		f, err := openFiles(SendFiles)
+		if err != nil {
+			return nil, nil, err
+		}
+		in = f
+	} else if RawStdin {
+		if err := makeRaw(); err != nil {
+			return nil, nil, err
+		}
+		in = rawReader{os.Stdin}
+	}
+	if ConfirmEOF && in == os.Stdin {
+		in = confirmEOF(in)
+	}
+	if HexSend && in != nil {
+		in = newHexReader(in)
+	}
+	var out io.WriteCloser
+	if SplitOutput != "" {
+		out = &splitWriter{}
+	} else {
+		out = stdout()
+	}
+	if BufferQueue > 0 {
+		out = newQueueWriter(out, BufferQueue, Overflow == "drop")
+	}
+	if Pausable {
+		out = newPauseWriter(out)
+	}
+	if Tail > 0 {
+		out = newTailWriter(out, Tail)
+	}
+	if StripANSI {
+		out = newANSIStripper(out)
+	}
+	in, out = format(in, out)
+	if JSONLines {
+		out = newJSONWriter(out)
+		if in != nil {
+			in = newJSONReader(in)
+		}
+	}
+	// Raw received data is matched, so it goes before rendering
+	if DiscardUntil != "" {
+		out = newDiscardWriter(out)
+	}
+	return in, out, nil
+}
+
+// transformWriter applies transform to every chunk before writing
+type transformWriter struct {
+	w         io.WriteCloser
+	transform func([]byte) []byte
+}
+
+func (t *transformWriter) Write(b []byte) (int, error) {
+	if _, err := t.w.Write(t.transform(b)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (t *transformWriter) Close() error {
+	return t.w.Close()
+}
+
+// reverse reverses bytes of the chunk, trailing line ending is kept in place
+func reverse(b []byte) []byte {
+	line := bytes.TrimRight(b, "\r\n")
+	r := make([]byte, 0, len(b))
+	for i := len(line) - 1; i >= 0; i-- {
+		r = append(r, line[i])
+	}
+	return append(r, b[len(line):]...)
+}
+
+var rot13 = mapASCII(func(c byte) byte { return 'a' + (c-'a'+13)%26 }, func(c byte) byte { return 'A' + (c-'A'+13)%26 })
+
+// mapASCII makes transformation which maps lowercase and uppercase ASCII letters by given funcs (nil keeps them as is),
+// other bytes are copied as is
+func mapASCII(lower func(byte) byte, upper func(byte) byte) func([]byte) []byte {
+	return func(b []byte) []byte {
+		r := make([]byte, len(b))
+		for i, c := range b {
+			switch {
+			case c >= 'a' && c <= 'z' && lower != nil:
+				c = lower(c)
+			case c >= 'A' && c <= 'Z' && upper != nil:
+				c = upper(c)
+			}
+			r[i] = c
+		}
+		return r
+	}
+}
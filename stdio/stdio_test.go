@@ -0,0 +1,37 @@
+package stdio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransforms(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform string
+		in        string
+		out       string
+	}{
+		{"none", "none", "Hello\n", "Hello\n"},
+		{"upper", "upper", "Hello, World\n", "HELLO, WORLD\n"},
+		{"lower", "lower", "Hello, World\n", "hello, world\n"},
+		{"rot13", "rot13", "Hello, World\n", "Uryyb, Jbeyq\n"},
+		{"reverse", "reverse", "abc\r\n", "cba\r\n"},
+		{"reverse without newline", "reverse", "abc", "cba"},
+		{"reverse newline only", "reverse", "\n", "\n"},
+		{"upper empty", "upper", "", ""},
+		// Non-ASCII letters are bytes to be kept as is
+		{"upper UTF-8", "upper", "привет\n", "привет\n"},
+		{"upper split UTF-8", "upper", "a\xd0", "A\xd0"},
+		{"lower invalid UTF-8", "lower", "A\xff\xfeB", "a\xff\xfeb"},
+		{"rot13 binary", "rot13", "\x00a\x80z\xff", "\x00n\x80m\xff"},
+		{"reverse binary", "reverse", "\x00\x01\xd0\xbf", "\xbf\xd0\x01\x00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := Transforms[tt.transform]([]byte(tt.in))
+			assert.Equal(t, []byte(tt.out), out)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package stdio
+
+import "io"
+
+// Tail makes only the last Tail bytes of received data to be printed once connection is closed, zero prints everything at once
+var Tail int
+
+// tailWriter keeps the last bytes written in ring buffer and writes them on Close
+type tailWriter struct {
+	w    io.WriteCloser
+	ring []byte
+	pos  int
+	full bool
+}
+
+func newTailWriter(w io.WriteCloser, size int) *tailWriter {
+	return &tailWriter{w: w, ring: make([]byte, size)}
+}
+
+func (t *tailWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	if n >= len(t.ring) {
+		// Only the end of chunk fits
+		copy(t.ring, b[n-len(t.ring):])
+		t.pos, t.full = 0, true
+		return n, nil
+	}
+	c := copy(t.ring[t.pos:], b)
+	if c < n {
+		copy(t.ring, b[c:])
+		t.full = true
+	}
+	t.pos = (t.pos + n) % len(t.ring)
+	if t.pos == 0 {
+		t.full = true
+	}
+	return n, nil
+}
+
+func (t *tailWriter) Close() error {
+	var err error
+	if t.full {
+		_, err = t.w.Write(append(t.ring[t.pos:], t.ring[:t.pos]...))
+	} else {
+		_, err = t.w.Write(t.ring[:t.pos])
+	}
+	if cerr := t.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package stdio
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// Tee is a file to archive received data to besides local destination
+var Tee string
+
+// TeeSend is a file to archive sent data to
+var TeeSend string
+
+// TeeHex makes received data to be dumped to standard error in canonical hexdump format besides local destination
+var TeeHex bool
+
+// tee duplicates received data written to out into Tee file and sent data read from in into TeeSend file.
+// Both in and out are closed on failure.
+func tee(in io.ReadCloser, out io.WriteCloser) (io.ReadCloser, io.WriteCloser, error) {
+	if Tee != "" {
+		f, err := os.Create(Tee)
+		if err != nil {
+			closeAll([]io.Closer{in, out})
+			return nil, nil, err
+		}
+		out = &teeWriter{Writer: io.MultiWriter(out, f), closers: []io.Closer{out, f}}
+	}
+	if TeeHex {
+		d := newHexDumper(os.Stderr)
+		out = &teeWriter{Writer: io.MultiWriter(out, d), closers: []io.Closer{out, d}}
+	}
+	if TeeSend != "" && in != nil {
+		f, err := os.Create(TeeSend)
+		if err != nil {
+			closeAll([]io.Closer{in, out})
+			return nil, nil, err
+		}
+		in = &teeReader{Reader: io.TeeReader(in, f), closers: []io.Closer{in, f}}
+	}
+	return in, out, nil
+}
+
+type teeWriter struct {
+	io.Writer
+	closers []io.Closer
+}
+
+func (t *teeWriter) Close() error {
+	return closeAll(t.closers)
+}
+
+// hexDumper renders hexdump with offsets running through all writes, output is flushed once per write
+// instead of once per byte, the last partial line is written on close
+type hexDumper struct {
+	buf  *bufio.Writer
+	dump io.WriteCloser
+}
+
+func newHexDumper(w io.Writer) *hexDumper {
+	buf := bufio.NewWriter(w)
+	return &hexDumper{buf: buf, dump: hex.Dumper(buf)}
+}
+
+func (h *hexDumper) Write(b []byte) (int, error) {
+	n, err := h.dump.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, h.buf.Flush()
+}
+
+func (h *hexDumper) Close() error {
+	h.dump.Close()
+	return h.buf.Flush()
+}
+
+type teeReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (t *teeReader) Close() error {
+	return closeAll(t.closers)
+}
+
+// closeAll closes everything (nils are skipped) and returns the first error
+func closeAll(closers []io.Closer) error {
+	var err error
+	for _, c := range closers {
+		if c == nil {
+			continue
+		}
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
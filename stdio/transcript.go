@@ -0,0 +1,90 @@
+package stdio
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Transcript is a file to write human-readable log of both directions to, sent lines are prefixed with ">" and received ones with "<"
+var Transcript string
+
+// transcript annotates sent data read from in and received data written to out, both in and out are closed on failure
+func transcript(in io.ReadCloser, out io.WriteCloser) (io.ReadCloser, io.WriteCloser, error) {
+	f, err := os.Create(Transcript)
+	if err != nil {
+		closeAll([]io.Closer{in, out})
+		return nil, nil, err
+	}
+	a := &annotator{f: f, refs: 1}
+	if in != nil {
+		a.refs++
+		in = &teeReader{Reader: io.TeeReader(in, a.direction(">")), closers: []io.Closer{in, a}}
+	}
+	out = &teeWriter{Writer: io.MultiWriter(out, a.direction("<")), closers: []io.Closer{out, a}}
+	return in, out, nil
+}
+
+// annotator writes chunks of both directions to file one by one, file is closed once both directions are closed
+type annotator struct {
+	sync.Mutex
+	f    *os.File
+	refs int
+}
+
+func (a *annotator) direction(prefix string) io.Writer {
+	return writerFunc(func(b []byte) (int, error) {
+		a.write(prefix, b)
+		return len(b), nil
+	})
+}
+
+// write logs every line of text chunk or the whole binary chunk as hex, failures don't affect transfer
+func (a *annotator) write(prefix string, b []byte) {
+	a.Lock()
+	defer a.Unlock()
+	stamp := time.Now().Format("15:04:05.000")
+	if !isText(b) {
+		fmt.Fprintf(a.f, "%s %s [hex] %s\n", stamp, prefix, hex.EncodeToString(b))
+		return
+	}
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if len(line) > 0 {
+			fmt.Fprintf(a.f, "%s %s %s\n", stamp, prefix, bytes.TrimRight(line, "\r\n"))
+		}
+	}
+}
+
+func (a *annotator) Close() error {
+	a.Lock()
+	defer a.Unlock()
+	if a.refs--; a.refs > 0 {
+		return nil
+	}
+	return a.f.Close()
+}
+
+// isText checks whether chunk is valid UTF-8 without control characters except tabs and line endings
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if unicode.IsControl(r) && r != '\t' && r != '\r' && r != '\n' {
+			return false
+		}
+	}
+	return true
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
@@ -0,0 +1,34 @@
+package stdio
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// SendURL is fetched and its body is sent instead of standard input
+var SendURL string
+
+// urlTimeout limits connection establishment and waiting for response headers, body is read at transfer pace
+const urlTimeout = 30 * time.Second
+
+// fetch returns body of successful GET response
+func fetch(url string) (io.ReadCloser, error) {
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: urlTimeout}).DialContext,
+		TLSHandshakeTimeout:   urlTimeout,
+		ResponseHeaderTimeout: urlTimeout,
+	}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Unable to fetch %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package tcp
+
+import (
+	"errors"
+	"net"
+)
+
+// setBacklog is not available, Winsock ignores listen() calls on already listening socket
+func setBacklog(ln net.Listener, backlog int) error {
+	return errors.New("listen backlog can't be changed on this platform")
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package tcp
+
+import (
+	"net"
+	"syscall"
+)
+
+// setBacklog calls listen(2) once again on already listening socket, which makes kernel to adopt new backlog.
+// Kernel still silently caps backlog to net.core.somaxconn (Linux) or kern.ipc.somaxconn (BSD).
+func setBacklog(ln net.Listener, backlog int) error {
+	rc, err := ln.(*net.TCPListener).SyscallConn()
+	if err != nil {
+		return err
+	}
+	var lerr error
+	err = rc.Control(func(fd uintptr) {
+		lerr = syscall.Listen(int(fd), backlog)
+	})
+	if err != nil {
+		return err
+	}
+	return lerr
+}
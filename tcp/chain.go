@@ -0,0 +1,60 @@
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ConnectVia is a comma-separated list of relay hops (i.e. relay1:9000,relay2:9000) client connects to target through.
+// The first hop is dialed and told the rest of path by chain header, every hop has to be started with ChainRelay.
+var ConnectVia string
+
+// ChainRelay makes relay to take upstream from chain header sent by client instead of Upstream
+var ChainRelay bool
+
+// Chain header is a single line "GONC-VIA <hop>,<hop>,...,<target>\n" sent first over connection to relay.
+// Relay dials the first address and passes the rest on unless it is the target itself.
+const chainPrefix = "GONC-VIA "
+
+// maxChainHeader limits length of chain header read by relay
+const maxChainHeader = 4096
+
+// hops splits ConnectVia into addresses
+func hops() []string {
+	return strings.Split(ConnectVia, ",")
+}
+
+// writeChainHeader tells relay the rest of path
+func writeChainHeader(w io.Writer, path []string) error {
+	_, err := io.WriteString(w, chainPrefix+strings.Join(path, ",")+"\n")
+	return err
+}
+
+// readChainHeader reads chain header byte by byte, so nothing sent after it is consumed
+func readChainHeader(r io.Reader) ([]string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, fmt.Errorf("Chain header hasn't been received: %s", err)
+		}
+		if b[0] == '\n' {
+			break
+		}
+		if line = append(line, b[0]); len(line) > maxChainHeader {
+			return nil, errors.New("Chain header is too long")
+		}
+	}
+	if !strings.HasPrefix(string(line), chainPrefix) {
+		return nil, errors.New("Chain header is malformed")
+	}
+	path := strings.Split(strings.TrimPrefix(string(line), chainPrefix), ",")
+	for _, addr := range path {
+		if addr == "" {
+			return nil, errors.New("Chain header contains empty address")
+		}
+	}
+	return path, nil
+}
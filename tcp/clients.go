@@ -0,0 +1,80 @@
+package tcp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dddpaul/gonc/stdio"
+	"github.com/dddpaul/gonc/transport"
+)
+
+// StartClients opens n connections concurrently, every one sends the same data read from standard input (or files) beforehand.
+// Data received from all connections is written to standard output, throughput is reported once all of them are over.
+func StartClients(proto string, host string, port string, n int) {
+	data, out := sharedStreams()
+	defer out.Release()
+	addr := transport.Address(host, port)
+	var received, sent, failed uint64
+	var wg sync.WaitGroup
+	started := time.Now()
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dialStarted := time.Now()
+			con, err := Dial(proto, host, port)
+			if err != nil {
+				atomic.AddUint64(&failed, 1)
+				log.Println(dialError(addr, err))
+				return
+			}
+			s := transport.Dialed(con, dialStarted)
+			s.Printf("Connected to %s\n", addr)
+			LogTLS(s, con)
+			var in io.ReadCloser
+			if !stdio.NoStdin {
+				in = io.NopCloser(bytes.NewReader(data))
+			}
+			Transfer(con, in, out)
+			elapsed := time.Since(s.Started)
+			s.Printf("Throughput: received %s, sent %s\n", throughput(s.Received(), elapsed), throughput(s.Sent(), elapsed))
+			atomic.AddUint64(&received, s.Received())
+			atomic.AddUint64(&sent, s.Sent())
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(started)
+	log.Printf("%d of %d connections have succeeded in %s, %d bytes has been received (%s), %d bytes has been sent (%s)\n",
+		uint64(n)-failed, n, elapsed, received, throughput(received, elapsed), sent, throughput(sent, elapsed))
+}
+
+// throughput formats transfer rate in megabytes (10^6 bytes) per second
+func throughput(bytes uint64, elapsed time.Duration) string {
+	if elapsed <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f MB/s", float64(bytes)/elapsed.Seconds()/1e6)
+}
+
+// sharedStreams sets local side up once for several connections the same way as for single one:
+// data to be sent is read up front and output is shared by all of them, it's closed by the caller once they are over
+func sharedStreams() ([]byte, *stdio.SharedWriter) {
+	in, out, err := stdio.Streams(nil, nil)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var data []byte
+	if in != nil {
+		data, err = io.ReadAll(in)
+		in.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	return data, stdio.NewSharedWriter(out)
+}
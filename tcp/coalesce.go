@@ -0,0 +1,79 @@
+package tcp
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// WriteCoalesce is a period small writes of sent data are gathered for before they go to connection together, zero disables it
+var WriteCoalesce time.Duration
+
+// coalesceSize is a size of gathered data which is sent at once without waiting for WriteCoalesce
+const coalesceSize = 16 << 10
+
+// coalescer gathers writes and flushes them once WriteCoalesce has passed since the first of them or coalesceSize is reached.
+// Failure of flush done by timer is returned by the next write.
+type coalescer struct {
+	w     io.WriteCloser
+	delay time.Duration
+	mu    sync.Mutex
+	buf   []byte
+	timer *time.Timer
+	err   error
+}
+
+func newCoalescer(w io.WriteCloser, delay time.Duration) *coalescer {
+	return &coalescer{w: w, delay: delay}
+}
+
+func (c *coalescer) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return 0, c.err
+	}
+	// Large write has nothing to gain from gathering
+	if len(c.buf) == 0 && len(b) >= coalesceSize {
+		return c.w.Write(b)
+	}
+	c.buf = append(c.buf, b...)
+	if len(c.buf) >= coalesceSize {
+		c.flushLocked()
+	} else if c.timer == nil {
+		c.timer = time.AfterFunc(c.delay, c.flush)
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+	return len(b), nil
+}
+
+func (c *coalescer) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+func (c *coalescer) flushLocked() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if len(c.buf) > 0 && c.err == nil {
+		_, c.err = c.w.Write(c.buf)
+	}
+	c.buf = c.buf[:0]
+}
+
+// Close sends whatever has been gathered before closing
+func (c *coalescer) Close() error {
+	c.mu.Lock()
+	c.flushLocked()
+	err := c.err
+	c.mu.Unlock()
+	if cerr := c.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
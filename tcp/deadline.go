@@ -0,0 +1,60 @@
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dddpaul/gonc/transport"
+)
+
+// FirstByteTimeout limits waiting for the first received byte, IdleTimeout applies after that, zero means no limit
+var FirstByteTimeout time.Duration
+
+// IdleTimeout limits period nothing is received for, zero means no limit
+var IdleTimeout time.Duration
+
+type readDeadliner interface {
+	SetReadDeadline(time.Time) error
+}
+
+// timeoutReader sets read deadline before every read, the first byte is waited for FirstByteTimeout and the rest for IdleTimeout
+type timeoutReader struct {
+	transport.Conn
+	d     readDeadliner
+	first bool
+}
+
+// withTimeouts returns con itself unless timeouts are set and con supports deadlines
+func withTimeouts(con transport.Conn) io.ReadCloser {
+	d, ok := con.(readDeadliner)
+	if !ok || FirstByteTimeout == 0 && IdleTimeout == 0 {
+		return con
+	}
+	return &timeoutReader{Conn: con, d: d, first: true}
+}
+
+func (t *timeoutReader) Read(b []byte) (int, error) {
+	timeout := IdleTimeout
+	if t.first {
+		timeout = FirstByteTimeout
+	}
+	if timeout > 0 {
+		t.d.SetReadDeadline(time.Now().Add(timeout))
+	} else {
+		t.d.SetReadDeadline(time.Time{})
+	}
+	n, err := t.Conn.Read(b)
+	if n > 0 {
+		t.first = false
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		if t.first {
+			return n, fmt.Errorf("No data has been received within %s, peer has never responded", timeout)
+		}
+		return n, fmt.Errorf("No data has been received for %s, peer has gone quiet", timeout)
+	}
+	return n, err
+}
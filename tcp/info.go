@@ -0,0 +1,37 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/dddpaul/gonc/transport"
+)
+
+// TCPInfo makes kernel statistics of connection (RTT, retransmits, congestion window and so on) to be logged
+// right before it's closed, Linux only
+var TCPInfo bool
+
+// logTCPInfo logs kernel statistics of connection, connections which aren't plain sockets (i.e. SSH channels) are skipped
+func logTCPInfo(s *transport.Session, con transport.Conn) {
+	tc, ok := socket(con)
+	if !ok {
+		return
+	}
+	info, err := tcpInfo(tc)
+	if err != nil {
+		s.Printf("WARNING: TCP info is unavailable: %s\n", err)
+		return
+	}
+	s.Printf("TCP info: %s\n", info)
+}
+
+// socket returns TCP socket connection is built on
+func socket(con transport.Conn) (*net.TCPConn, bool) {
+	switch c := unwrap(con).(type) {
+	case *net.TCPConn:
+		return c, true
+	case *tls.Conn:
+		return socket(c.NetConn())
+	}
+	return nil, false
+}
@@ -0,0 +1,79 @@
+package tcp
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dddpaul/gonc/transport"
+)
+
+// InspectTLS makes TLS record headers of received plaintext data to be logged (content type, version and length),
+// data itself is written as is and isn't decrypted
+var InspectTLS bool
+
+// recordTypes are names of TLS record content types
+var recordTypes = map[byte]string{
+	20: "change_cipher_spec",
+	21: "alert",
+	22: "handshake",
+	23: "application_data",
+	24: "heartbeat",
+}
+
+// tlsInspector parses TLS record framing of written data, records split across writes are handled.
+// Inspection stops once data doesn't look like TLS record header.
+type tlsInspector struct {
+	io.WriteCloser
+	s       *transport.Session
+	header  []byte
+	skip    int
+	stopped bool
+}
+
+func (t *tlsInspector) Write(b []byte) (int, error) {
+	if !t.stopped {
+		t.inspect(b)
+	}
+	return t.WriteCloser.Write(b)
+}
+
+func (t *tlsInspector) inspect(b []byte) {
+	for len(b) > 0 && !t.stopped {
+		if t.skip > 0 {
+			n := t.skip
+			if n > len(b) {
+				n = len(b)
+			}
+			t.skip -= n
+			b = b[n:]
+			continue
+		}
+		n := 5 - len(t.header)
+		if n > len(b) {
+			n = len(b)
+		}
+		t.header = append(t.header, b[:n]...)
+		b = b[n:]
+		if len(t.header) < 5 {
+			return
+		}
+		name, ok := recordTypes[t.header[0]]
+		if !ok || t.header[1] != 3 {
+			t.s.Printf("TLS inspection: data doesn't look like TLS record header (% x), inspection has been stopped\n", t.header)
+			t.stopped = true
+			return
+		}
+		length := int(t.header[3])<<8 | int(t.header[4])
+		t.s.Printf("TLS record: %s, %s, %d bytes\n", name, recordVersion(t.header[2]), length)
+		t.header = t.header[:0]
+		t.skip = length
+	}
+}
+
+// recordVersion names record layer version, TLS 1.3 records claim to be TLS 1.2 for compatibility
+func recordVersion(minor byte) string {
+	if minor == 0 {
+		return "SSL 3.0"
+	}
+	return fmt.Sprintf("TLS 1.%d", minor-1)
+}
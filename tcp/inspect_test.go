@@ -0,0 +1,64 @@
+package tcp
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dddpaul/gonc/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSInspector(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	tests := []struct {
+		name   string
+		in     string
+		logged []string
+	}{
+		{"handshake record", "\x16\x03\x01\x00\x02ab", []string{"TLS record: handshake, TLS 1.0, 2 bytes"}},
+		{"two records", "\x16\x03\x03\x00\x01a\x14\x03\x03\x00\x01\x01", []string{
+			"TLS record: handshake, TLS 1.2, 1 bytes",
+			"TLS record: change_cipher_spec, TLS 1.2, 1 bytes",
+		}},
+		{"SSL 3.0 alert", "\x15\x03\x00\x00\x02\x02\x28", []string{"TLS record: alert, SSL 3.0, 2 bytes"}},
+		{"empty record", "\x17\x03\x04\x00\x00\x18\x03\x03\x00\x01x", []string{
+			"TLS record: application_data, TLS 1.3, 0 bytes",
+			"TLS record: heartbeat, TLS 1.2, 1 bytes",
+		}},
+		{"plaintext", "GET / HTTP/1.1\r\n", []string{
+			"TLS inspection: data doesn't look like TLS record header (47 45 54 20 2f), inspection has been stopped",
+		}},
+		{"plaintext after record", "\x17\x03\x03\x00\x01xGET / HTTP/1.1\r\n", []string{
+			"TLS record: application_data, TLS 1.2, 1 bytes",
+			"TLS inspection: data doesn't look like TLS record header (47 45 54 20 2f), inspection has been stopped",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every chunk size splits headers and records at every possible position
+			for size := 1; size <= len(tt.in); size++ {
+				var logged bytes.Buffer
+				log.SetOutput(&logged)
+				out := transport.NewMemory(nil)
+				w := &tlsInspector{WriteCloser: out, s: transport.Open(transport.NewMemory(nil))}
+				for _, chunk := range transport.Chunks(tt.in, size) {
+					n, err := w.Write([]byte(chunk))
+					assert.NoError(t, err)
+					assert.Equal(t, len(chunk), n)
+				}
+				// Data is written as is regardless of inspection
+				assert.Equal(t, tt.in, out.Written.String(), "chunk size %d", size)
+				var messages []string
+				for _, line := range strings.Split(strings.TrimSuffix(logged.String(), "\n"), "\n") {
+					if _, msg, ok := strings.Cut(line, "]: "); ok {
+						messages = append(messages, msg)
+					}
+				}
+				assert.Equal(t, tt.logged, messages, "chunk size %d", size)
+			}
+		})
+	}
+}
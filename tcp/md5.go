@@ -0,0 +1,60 @@
+package tcp
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// TCPMD5 is a TCP MD5 signature key (RFC 2385, used by BGP) of connection, Linux only
+var TCPMD5 string
+
+// MD5Peer is an address of peer listener accepts MD5 signed connection from, client signs connection to dialed address
+var MD5Peer string
+
+// maxMD5Key is a maximum key length accepted by kernel
+const maxMD5Key = 80
+
+// CheckMD5 validates TCP MD5 signature settings
+func CheckMD5(listen bool) error {
+	if TCPMD5 == "" {
+		return nil
+	}
+	if len(TCPMD5) > maxMD5Key {
+		return errors.New("TCP MD5 signature key is longer than 80 bytes")
+	}
+	if listen && net.ParseIP(MD5Peer) == nil {
+		return errors.New("TCP MD5 signature requires -host to be peer IP address in listen mode")
+	}
+	return nil
+}
+
+// md5Control returns socket control function which sets TCP MD5 signature key for peer, dialed address is a peer if it's nil
+func md5Control(peer net.IP) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		ip := peer
+		if ip == nil {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip = net.ParseIP(host)
+		}
+		var serr error
+		if err := c.Control(func(fd uintptr) {
+			serr = setMD5(fd, network == "tcp6", ip, TCPMD5)
+		}); err != nil {
+			return err
+		}
+		return serr
+	}
+}
+
+// dialer returns dialer with Timeout and TCP MD5 signature applied
+func dialer() net.Dialer {
+	d := net.Dialer{Timeout: Timeout}
+	if TCPMD5 != "" {
+		d.Control = md5Control(nil)
+	}
+	return d
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package tcp
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// setMD5 sets signature key for peer on socket, IPv4 peer of IPv6 socket is given as IPv4-mapped address
+func setMD5(fd uintptr, ipv6 bool, peer net.IP, key string) error {
+	sig := unix.TCPMD5Sig{Keylen: uint16(len(key))}
+	copy(sig.Key[:], key)
+	// Port goes first in sockaddr data, it's zero since key applies to every port of peer
+	if ipv6 {
+		sig.Addr.Family = unix.AF_INET6
+		copy(sig.Addr.Data[6:22], peer.To16())
+	} else {
+		ip4 := peer.To4()
+		if ip4 == nil {
+			return fmt.Errorf("IPv6 peer %s of IPv4 socket can't get TCP MD5 signature", peer)
+		}
+		sig.Addr.Family = unix.AF_INET
+		copy(sig.Addr.Data[2:6], ip4)
+	}
+	if err := unix.SetsockoptTCPMD5Sig(int(fd), unix.IPPROTO_TCP, unix.TCP_MD5SIG, &sig); err != nil {
+		return fmt.Errorf("Unable to set TCP MD5 signature: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package tcp
+
+import (
+	"errors"
+	"net"
+)
+
+// setMD5 is not available, TCP_MD5SIG is Linux-specific
+func setMD5(fd uintptr, ipv6 bool, peer net.IP, key string) error {
+	return errors.New("TCP MD5 signature is supported on Linux only")
+}
@@ -0,0 +1,70 @@
+package tcp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TLSPin is SHA-256 fingerprint of server leaf certificate (sha256:HEX), server is trusted if it matches regardless of CA
+var TLSPin string
+
+// TLSPinSPKI is SHA-256 fingerprint of server public key (sha256:HEX), it survives certificate renewal with the same key
+var TLSPinSPKI string
+
+var certPin, spkiPin []byte
+
+// parsePin decodes sha256:HEX fingerprint, hex digits may be separated by colons
+func parsePin(pin string) ([]byte, error) {
+	if !strings.HasPrefix(pin, "sha256:") {
+		return nil, fmt.Errorf("Pin %q has to be sha256:HEX", pin)
+	}
+	digest, err := hex.DecodeString(strings.ReplaceAll(strings.TrimPrefix(pin, "sha256:"), ":", ""))
+	if err != nil || len(digest) != sha256.Size {
+		return nil, fmt.Errorf("Pin %q isn't valid SHA-256 fingerprint", pin)
+	}
+	return digest, nil
+}
+
+// checkPins parses TLSPin and TLSPinSPKI
+func checkPins() error {
+	var err error
+	if TLSPin != "" {
+		if certPin, err = parsePin(TLSPin); err != nil {
+			return err
+		}
+	}
+	if TLSPinSPKI != "" {
+		if spkiPin, err = parsePin(TLSPinSPKI); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pinned() bool {
+	return certPin != nil || spkiPin != nil
+}
+
+// verifyPins compares fingerprints of server leaf certificate with pins, actual fingerprint is reported on mismatch
+func verifyPins(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("Server hasn't presented certificate to verify pin against")
+	}
+	leaf := state.PeerCertificates[0]
+	if certPin != nil {
+		if digest := sha256.Sum256(leaf.Raw); !bytes.Equal(digest[:], certPin) {
+			return fmt.Errorf("Certificate pin mismatch, server certificate is sha256:%x", digest)
+		}
+	}
+	if spkiPin != nil {
+		if digest := sha256.Sum256(leaf.RawSubjectPublicKeyInfo); !bytes.Equal(digest[:], spkiPin) {
+			return fmt.Errorf("Public key pin mismatch, server public key is sha256:%x", digest)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package tcp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ProxyProtocolV2 makes PROXY protocol v2 header to be sent first over every dialed connection
+var ProxyProtocolV2 bool
+
+// TLV is a type-length-value extension of PROXY protocol v2 header
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// TLVList is a flag value which takes type=value pairs and may be repeated.
+// Type is decimal or 0x prefixed hex, value is text or 0x prefixed hex.
+type TLVList []TLV
+
+func (l *TLVList) String() string {
+	var pairs []string
+	for _, tlv := range *l {
+		pairs = append(pairs, fmt.Sprintf("0x%02x=0x%x", tlv.Type, tlv.Value))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set appends type=value pair
+func (l *TLVList) Set(value string) error {
+	kind, data, ok := strings.Cut(value, "=")
+	if !ok {
+		return errors.New("TLV must be given as type=value")
+	}
+	t, err := strconv.ParseUint(kind, 0, 8)
+	if err != nil {
+		return fmt.Errorf("Invalid TLV type %q, it must be within 0-255", kind)
+	}
+	v := []byte(data)
+	if strings.HasPrefix(data, "0x") {
+		if v, err = hex.DecodeString(data[2:]); err != nil {
+			return fmt.Errorf("Invalid hex TLV value: %s", err)
+		}
+	}
+	*l = append(*l, TLV{Type: byte(t), Value: v})
+	return nil
+}
+
+// ProxyTLVs are added to PROXY protocol v2 header
+var ProxyTLVs TLVList
+
+// proxySignature starts every PROXY protocol v2 header
+var proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// writeProxyHeader sends PROXY protocol v2 header describing TCP connection from src to dst
+func writeProxyHeader(w io.Writer, src net.Addr, dst net.Addr) error {
+	s, sok := src.(*net.TCPAddr)
+	d, dok := dst.(*net.TCPAddr)
+	if !sok || !dok {
+		return errors.New("PROXY protocol requires TCP addresses")
+	}
+	var family byte
+	var addrs []byte
+	switch {
+	case s.IP.To4() != nil && d.IP.To4() != nil:
+		// TCP over IPv4
+		family = 0x11
+		addrs = append(append(addrs, s.IP.To4()...), d.IP.To4()...)
+	case s.IP.To4() == nil && d.IP.To4() == nil:
+		// TCP over IPv6
+		family = 0x21
+		addrs = append(append(addrs, s.IP.To16()...), d.IP.To16()...)
+	default:
+		return fmt.Errorf("PROXY protocol addresses %s and %s belong to different families", s.IP, d.IP)
+	}
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(s.Port))
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(d.Port))
+	for _, tlv := range ProxyTLVs {
+		addrs = append(addrs, tlv.Type)
+		addrs = binary.BigEndian.AppendUint16(addrs, uint16(len(tlv.Value)))
+		addrs = append(addrs, tlv.Value...)
+	}
+	if len(addrs) > 0xffff {
+		return errors.New("PROXY protocol header is too long")
+	}
+	// Version 2, PROXY command
+	header := append(append([]byte{}, proxySignature...), 0x21, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+	_, err := w.Write(append(header, addrs...))
+	return err
+}
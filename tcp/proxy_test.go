@@ -0,0 +1,84 @@
+package tcp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteProxyHeader(t *testing.T) {
+	defer func() { ProxyTLVs = nil }()
+	v4 := func(ip string, port int) net.Addr { return &net.TCPAddr{IP: net.ParseIP(ip).To4(), Port: port} }
+	v6 := func(ip string, port int) net.Addr { return &net.TCPAddr{IP: net.ParseIP(ip), Port: port} }
+	tests := []struct {
+		name   string
+		src    net.Addr
+		dst    net.Addr
+		tlvs   TLVList
+		header string
+		err    string
+	}{
+		{"IPv4", v4("10.0.0.1", 40000), v4("10.0.0.2", 443), nil,
+			"\x21\x11\x00\x0c" + "\x0a\x00\x00\x01" + "\x0a\x00\x00\x02" + "\x9c\x40" + "\x01\xbb", ""},
+		{"IPv4 given as 16 bytes", v6("10.0.0.1", 1), v6("10.0.0.2", 2), nil,
+			"\x21\x11\x00\x0c" + "\x0a\x00\x00\x01" + "\x0a\x00\x00\x02" + "\x00\x01" + "\x00\x02", ""},
+		{"IPv6", v6("::1", 1), v6("2001:db8::2", 2), nil,
+			"\x21\x21\x00\x24" + strings.Repeat("\x00", 15) + "\x01" + "\x20\x01\x0d\xb8" + strings.Repeat("\x00", 11) + "\x02" + "\x00\x01" + "\x00\x02", ""},
+		{"TLVs", v4("10.0.0.1", 1), v4("10.0.0.2", 2), TLVList{{Type: 0xea, Value: []byte("vpce")}, {Type: 0x01, Value: []byte{0x00, 0xff}}},
+			"\x21\x11\x00\x18" + "\x0a\x00\x00\x01" + "\x0a\x00\x00\x02" + "\x00\x01" + "\x00\x02" + "\xea\x00\x04vpce" + "\x01\x00\x02\x00\xff", ""},
+		{"empty TLV", v4("10.0.0.1", 1), v4("10.0.0.2", 2), TLVList{{Type: 0x04}},
+			"\x21\x11\x00\x0f" + "\x0a\x00\x00\x01" + "\x0a\x00\x00\x02" + "\x00\x01" + "\x00\x02" + "\x04\x00\x00", ""},
+		{"mixed families", v4("10.0.0.1", 1), v6("::1", 2), nil, "", "different families"},
+		{"not TCP", &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1}, v4("10.0.0.2", 2), nil, "", "requires TCP addresses"},
+		{"too long", v4("10.0.0.1", 1), v4("10.0.0.2", 2), TLVList{{Type: 1, Value: make([]byte, 0xffff)}}, "", "too long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ProxyTLVs = tt.tlvs
+			var b bytes.Buffer
+			err := writeProxyHeader(&b, tt.src, tt.dst)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				assert.Zero(t, b.Len())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, string(proxySignature)+tt.header, b.String())
+		})
+	}
+}
+
+func TestTLVListSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		tlv   TLV
+		err   bool
+	}{
+		{"text value", "0xea=vpce-0123", TLV{Type: 0xea, Value: []byte("vpce-0123")}, false},
+		{"decimal type", "4=x", TLV{Type: 4, Value: []byte("x")}, false},
+		{"hex value", "1=0x00ff", TLV{Type: 1, Value: []byte{0x00, 0xff}}, false},
+		{"empty value", "1=", TLV{Type: 1, Value: []byte{}}, false},
+		{"value with equals sign", "1=a=b", TLV{Type: 1, Value: []byte("a=b")}, false},
+		{"no value", "1", TLV{}, true},
+		{"type out of range", "256=x", TLV{}, true},
+		{"invalid type", "x=1", TLV{}, true},
+		{"invalid hex value", "1=0xzz", TLV{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l TLVList
+			err := l.Set(tt.value)
+			if tt.err {
+				assert.Error(t, err)
+				assert.Empty(t, l)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, TLVList{tt.tlv}, l)
+		})
+	}
+}
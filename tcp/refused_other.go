@@ -0,0 +1,13 @@
+//go:build !windows
+
+package tcp
+
+import (
+	"errors"
+	"syscall"
+)
+
+// refused reports whether connection has been refused by remote host
+func refused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,13 @@
+package tcp
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// refused reports whether connection has been refused by remote host, Winsock reports it by its own error code
+func refused(err error) bool {
+	return errors.Is(err, windows.WSAECONNREFUSED) || errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,99 @@
+package tcp
+
+import (
+	"log"
+	"net"
+	"time"
+
+	"github.com/dddpaul/gonc/transport"
+)
+
+// Upstream is an address relay dials for every accepted connection, i.e. db.internal:5432
+var Upstream string
+
+// MaxConns limits number of connections relayed at once, the next one isn't accepted until a slot is free, zero means no limit
+var MaxConns int
+
+// Relaying checks whether listener relays accepted connections instead of using local side
+func Relaying() bool {
+	return Upstream != "" || ChainRelay
+}
+
+// StartRelay accepts connections on addr and relays every one to its own upstream connection dialed once it has been accepted
+func StartRelay(proto string, addr string) {
+	ln, raw := listen(proto, addr)
+	var slots chan struct{}
+	if MaxConns > 0 {
+		slots = make(chan struct{}, MaxConns)
+	}
+	for first := true; ; first = false {
+		if slots != nil {
+			slots <- struct{}{}
+		}
+		con, err := ln.Accept()
+		if first && transport.IsAcceptTimeout(err) {
+			log.Fatalf("No connection has been received within %s\n", transport.AcceptTimeout)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if first && transport.AcceptTimeout > 0 {
+			raw.SetDeadline(time.Time{})
+		}
+		go func() {
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+			relay(proto, con)
+		}()
+	}
+}
+
+// relay dials upstream (taken from chain header if ChainRelay is set) and transfers data between it and accepted connection, accepted one is closed if dial has failed
+func relay(proto string, con net.Conn) {
+	s, ok := opened(con)
+	if !ok {
+		return
+	}
+	upstream, path := Upstream, []string(nil)
+	if ChainRelay {
+		hops, err := readChainHeader(con)
+		if err != nil {
+			s.Errorf("%s, connection has been closed\n", err)
+			s.Close()
+			con.Close()
+			return
+		}
+		upstream, path = hops[0], hops[1:]
+	}
+	// TLS is terminated by relay, upstream connection is a plain one
+	up, err := net.DialTimeout(proto, upstream, Timeout)
+	if err != nil {
+		s.Errorf("Unable to connect to upstream, connection has been closed: %s\n", dialError(upstream, err))
+		s.Close()
+		con.Close()
+		return
+	}
+	// The next hop is a relay as well
+	if len(path) > 0 {
+		if err := writeChainHeader(up, path); err != nil {
+			s.Errorf("Unable to send chain header, connection has been closed: %s\n", err)
+			s.Close()
+			con.Close()
+			up.Close()
+			return
+		}
+	}
+	// Upstream learns real client addresses
+	if ProxyProtocolV2 {
+		if err := writeProxyHeader(up, con.RemoteAddr(), con.LocalAddr()); err != nil {
+			s.Errorf("Unable to send PROXY protocol header, connection has been closed: %s\n", err)
+			s.Close()
+			con.Close()
+			up.Close()
+			return
+		}
+	}
+	s.Printf("Connected to upstream %s\n", upstream)
+	Transfer(con, up, up)
+}
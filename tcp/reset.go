@@ -0,0 +1,107 @@
+package tcp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dddpaul/gonc/transport"
+)
+
+// RetryOnReset makes client to connect again and go on receiving once connection has been reset by peer (ECONNRESET),
+// data in flight at the moment of reset is lost
+var RetryOnReset bool
+
+// resetConn is client connection which is replaced by the new one once it has been reset
+type resetConn struct {
+	proto, host, port string
+	mu                sync.Mutex
+	con               net.Conn
+	closed            bool
+}
+
+func newResetConn(con net.Conn, proto string, host string, port string) *resetConn {
+	return &resetConn{proto: proto, host: host, port: port, con: con}
+}
+
+// unwrap returns connection which resetConn currently goes over, so its concrete type can be checked, other connections are returned as is
+func unwrap(con transport.Conn) transport.Conn {
+	if c, ok := con.(*resetConn); ok {
+		return c.current()
+	}
+	return con
+}
+
+func (c *resetConn) current() net.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.con
+}
+
+// Read goes on with the new connection if the old one has been reset, the reset is returned if reconnection has failed
+func (c *resetConn) Read(b []byte) (int, error) {
+	for {
+		n, err := c.current().Read(b)
+		if err == nil || !errors.Is(err, syscall.ECONNRESET) || !c.redial() {
+			return n, err
+		}
+	}
+}
+
+// redial replaces reset connection, false is returned if connection has been closed meanwhile or it can't be opened again
+func (c *resetConn) redial() bool {
+	s := transport.Lookup(c)
+	addr := transport.Address(c.host, c.port)
+	s.Printf("Connection has been reset by peer, reconnecting to %s in %s\n", addr, RetryInterval)
+	time.Sleep(RetryInterval)
+	con, err := Dial(c.proto, c.host, c.port)
+	if err != nil {
+		s.Errorf("%s\n", dialError(addr, err))
+		return false
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		con.Close()
+		return false
+	}
+	old := c.con
+	c.con = con
+	c.mu.Unlock()
+	old.Close()
+	s.Printf("Reconnected to %s\n", addr)
+	return true
+}
+
+func (c *resetConn) Write(b []byte) (int, error) {
+	return c.current().Write(b)
+}
+
+func (c *resetConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.con.Close()
+}
+
+func (c *resetConn) LocalAddr() net.Addr {
+	return c.current().LocalAddr()
+}
+
+func (c *resetConn) RemoteAddr() net.Addr {
+	return c.current().RemoteAddr()
+}
+
+func (c *resetConn) SetDeadline(t time.Time) error {
+	return c.current().SetDeadline(t)
+}
+
+func (c *resetConn) SetReadDeadline(t time.Time) error {
+	return c.current().SetReadDeadline(t)
+}
+
+func (c *resetConn) SetWriteDeadline(t time.Time) error {
+	return c.current().SetWriteDeadline(t)
+}
@@ -0,0 +1,116 @@
+package tcp
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dddpaul/gonc/transport"
+)
+
+// SelfTestSize is a size of pattern pumped through loopback connection by SelfTest
+const SelfTestSize = 64 << 20
+
+// SelfTest sends pattern over loopback connection to echoing listener and verifies it has come back intact.
+// Both sides are served by Transfer, so the whole transfer path is exercised.
+func SelfTest() error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	// Client closes connection once the whole pattern has come back
+	keep := transport.KeepOnEOF
+	transport.KeepOnEOF = true
+	defer func() { transport.KeepOnEOF = keep }()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		con, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		transport.Open(con).Printf("Echoing connection has been accepted\n")
+		r, w := io.Pipe()
+		Transfer(con, r, w)
+	}()
+
+	started := time.Now()
+	con, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		return err
+	}
+	v := &verifier{con: con}
+	Transfer(con, &pattern{size: SelfTestSize}, v)
+	elapsed := time.Since(started)
+	wg.Wait()
+
+	if v.err != nil {
+		return v.err
+	}
+	if v.n != SelfTestSize {
+		return fmt.Errorf("Self-test has failed: %d of %d bytes have come back", v.n, SelfTestSize)
+	}
+	log.Printf("Self-test has passed: %d bytes have been sent both ways in %s, %s\n", SelfTestSize, elapsed, throughput(2*SelfTestSize, elapsed))
+	return nil
+}
+
+// patternByte is a byte at offset i of pattern, period is prime so it doesn't align with buffer sizes
+func patternByte(i int64) byte {
+	return byte(i % 251)
+}
+
+// pattern reads size bytes of pattern
+type pattern struct {
+	size int64
+	n    int64
+}
+
+func (p *pattern) Read(b []byte) (int, error) {
+	if p.n >= p.size {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.size-p.n {
+		b = b[:p.size-p.n]
+	}
+	for i := range b {
+		b[i] = patternByte(p.n + int64(i))
+	}
+	p.n += int64(len(b))
+	return len(b), nil
+}
+
+func (p *pattern) Close() error {
+	return nil
+}
+
+// verifier compares received data with pattern and closes connection once the whole pattern or a mismatch has come
+type verifier struct {
+	con net.Conn
+	n   int64
+	err error
+}
+
+func (v *verifier) Write(b []byte) (int, error) {
+	for i, c := range b {
+		if c != patternByte(v.n+int64(i)) {
+			v.err = fmt.Errorf("Self-test has failed: byte %d is corrupted", v.n+int64(i))
+			v.con.Close()
+			return i, v.err
+		}
+	}
+	v.n += int64(len(b))
+	if v.n >= SelfTestSize {
+		v.con.Close()
+	}
+	return len(b), nil
+}
+
+func (v *verifier) Close() error {
+	return nil
+}
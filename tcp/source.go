@@ -0,0 +1,71 @@
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// SourcePortRange is a range of local ports (i.e. 20000-20100) outgoing connections are bound to, every connection gets its own port
+var SourcePortRange string
+
+// sourcePorts hands out ports of SourcePortRange one by one, the port of failed attempt isn't given again
+var sourcePorts struct {
+	sync.Mutex
+	next, last int
+}
+
+// CheckSourcePorts parses SourcePortRange
+func CheckSourcePorts() error {
+	if SourcePortRange == "" {
+		return nil
+	}
+	first, last, ok := strings.Cut(SourcePortRange, "-")
+	if !ok {
+		last = first
+	}
+	from, err := strconv.ParseUint(first, 10, 16)
+	if err != nil {
+		return fmt.Errorf("Invalid source port range %q", SourcePortRange)
+	}
+	to, err := strconv.ParseUint(last, 10, 16)
+	if err != nil || from == 0 || to < from {
+		return fmt.Errorf("Invalid source port range %q", SourcePortRange)
+	}
+	sourcePorts.next, sourcePorts.last = int(from), int(to)
+	return nil
+}
+
+// nextSourcePort returns the next unused port of the range, false if the range is exhausted
+func nextSourcePort() (int, bool) {
+	sourcePorts.Lock()
+	defer sourcePorts.Unlock()
+	if sourcePorts.next > sourcePorts.last {
+		return 0, false
+	}
+	sourcePorts.next++
+	return sourcePorts.next - 1, true
+}
+
+// dialFromRange connects from the next port of SourcePortRange, ports already in use are skipped
+func dialFromRange(proto string, addr string) (net.Conn, error) {
+	for {
+		port, ok := nextSourcePort()
+		if !ok {
+			return nil, fmt.Errorf("Source port range %s has been exhausted", SourcePortRange)
+		}
+		d := dialer()
+		d.LocalAddr = &net.TCPAddr{Port: port}
+		con, err := d.Dial(proto, addr)
+		if errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL) {
+			log.Printf("Source port %d is in use, trying the next one\n", port)
+			continue
+		}
+		return con, err
+	}
+}
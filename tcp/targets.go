@@ -0,0 +1,48 @@
+package tcp
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/dddpaul/gonc/stdio"
+	"github.com/dddpaul/gonc/transport"
+)
+
+// StartTargets connects to every target (host:port) in turn and sends the same data read from standard input (or files) beforehand.
+// Data received from all targets is written to standard output, summary is logged once all of them are over.
+func StartTargets(proto string, targets []string) {
+	data, out := sharedStreams()
+	defer out.Release()
+	var received, sent, failed uint64
+	started := time.Now()
+	for _, target := range targets {
+		host, port, err := net.SplitHostPort(target)
+		if err != nil {
+			failed++
+			log.Printf("Target %q has been skipped: %s\n", target, err)
+			continue
+		}
+		dialStarted := time.Now()
+		con, err := Dial(proto, host, ":"+port)
+		if err != nil {
+			failed++
+			log.Println(dialError(target, err))
+			continue
+		}
+		s := transport.Dialed(con, dialStarted)
+		s.Printf("Connected to %s\n", target)
+		LogTLS(s, con)
+		var in io.ReadCloser
+		if !stdio.NoStdin {
+			in = io.NopCloser(bytes.NewReader(data))
+		}
+		Transfer(con, in, out)
+		received += s.Received()
+		sent += s.Sent()
+	}
+	log.Printf("%d of %d targets have succeeded in %s, %d bytes has been received, %d bytes has been sent\n",
+		uint64(len(targets))-failed, len(targets), time.Since(started), received, sent)
+}
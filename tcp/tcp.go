@@ -1,64 +1,396 @@
 package tcp
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
-	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dddpaul/gonc/stdio"
+	"github.com/dddpaul/gonc/transport"
+	"github.com/dddpaul/gonc/tunnel"
 )
 
+// NagleOffAfter is an idle period after which the next write to connection is treated as the start of a new burst.
+// First write of every burst is sent with Nagle's algorithm disabled, the rest of burst is coalesced by Nagle.
+// Zero value keeps Go default (Nagle's algorithm is always disabled).
+var NagleOffAfter time.Duration
+
+// Timeout limits time of connection establishment, zero means no limit
+var Timeout time.Duration
+
+// Handshake is sent to accepted connection before anything else in listen mode, i.e. server greeting
+var Handshake []byte
+
+// CloseAfterSend makes connection to be closed once local input is over without waiting for receiving to end,
+// it's for fire-and-forget clients of protocols without response
+var CloseAfterSend bool
+
+// WaitForListener makes client to retry refused connection (remote listener isn't up yet), other errors are likely permanent
+var WaitForListener bool
+
+// Retry is a number of retries of refused connection if WaitForListener is set
+var Retry int
+
+// RetryInterval is a pause before every retry
+var RetryInterval = time.Second
+
+// Backlog is a listen backlog (maximum length of pending connections queue), zero keeps system default
+var Backlog int
+
+// DrainStdin is kept for compatibility only: input is always stopped once connection has been closed by remote peer
+var DrainStdin bool
+
+// MaxBuffer limits adaptive buffer of every direction, it's enough to read full-size TCP segment at once
+const MaxBuffer = 2<<16 - 1
+
 // Progress indicates transfer status
 type Progress struct {
 	bytes uint64
+	// kept is set if connection has been left open after local input is over
+	kept bool
+	// exited is set if exit pattern has been received
+	exited bool
 }
 
 // TransferStreams launches two read-write goroutines and waits for signal from them
 func TransferStreams(con net.Conn) {
-	c := make(chan Progress)
+	in, out, err := stdio.Streams(con.LocalAddr(), con.RemoteAddr())
+	if err != nil {
+		s := transport.Lookup(con)
+		s.Errorf("%s\n", err)
+		s.Close()
+		con.Close()
+		return
+	}
+	Transfer(con, in, out)
+}
+
+// Transfer is TransferStreams with local side given by in and out instead of local side from stdio, nothing is sent if in is nil.
+// When one direction ends the other one is unblocked by closing connection and input, so both goroutines always finish.
+func Transfer(con transport.Conn, in io.ReadCloser, out io.WriteCloser) {
+	s := transport.Lookup(con)
+	defer s.Close()
+	// Receiving goroutine may be left behind by CloseAfterSend, its progress mustn't block it
+	received := make(chan Progress, 1)
+	sent := make(chan Progress, 1)
+	var info sync.Once
 
 	// Read from Reader and write to Writer until EOF
-	copy := func(r io.ReadCloser, w io.WriteCloser) {
-		defer func() {
-			r.Close()
+	copy := func(r io.ReadCloser, w io.WriteCloser, c chan Progress) {
+		var n int64
+		var err error
+		switch {
+		case transport.AdaptiveBuffer:
+			n, err = transport.Copy(w, r, transport.Limited(MaxBuffer))
+		case transport.ReadSize > 0:
+			// Buffer is used as is only if neither side implements shortcuts (i.e. splice)
+			n, err = io.CopyBuffer(struct{ io.Writer }{w}, struct{ io.Reader }{r}, make([]byte, transport.ReadSize))
+		default:
+			n, err = io.Copy(w, r)
+		}
+		// Both are closed before progress is reported, so local side (i.e. executed command) is done when Transfer returns.
+		// Connection is still receiving after local input is over if asked.
+		kept := c == sent && err == nil && transport.KeepOnEOF
+		// Statistics are taken before connection is closed by either direction
+		if TCPInfo && (c == received || !kept) {
+			info.Do(func() { logTCPInfo(s, con) })
+		}
+		r.Close()
+		if !kept {
 			w.Close()
-		}()
-		n, err := io.Copy(w, r)
-		if err != nil {
-			log.Printf("[%s]: ERROR: %s\n", con.RemoteAddr(), err)
 		}
-		c <- Progress{bytes: uint64(n)}
+		// Connection is closed deliberately when the other direction has ended
+		exited := errors.Is(err, stdio.ErrExit)
+		if exited {
+			s.Printf("%s\n", stdio.ErrExit)
+		} else if err != nil && !errors.Is(err, net.ErrClosed) {
+			s.Errorf("%s\n", err)
+		}
+		c <- Progress{bytes: uint64(n), kept: kept, exited: exited}
+	}
+
+	var w io.WriteCloser = con
+	if tc, ok := con.(*net.TCPConn); ok && NagleOffAfter > 0 {
+		w = newBurstWriter(tc, NagleOffAfter)
+	}
+	if WriteCoalesce > 0 {
+		w = newCoalescer(w, WriteCoalesce)
+	}
+
+	// There is no sending goroutine at all without input
+	directions := 1
+	if in != nil {
+		directions = 2
+		// Input may be a terminal which can't be unblocked by closing, sending goroutine would wait for it forever
+		in = stdio.NewCancelReader(in)
 	}
+	defer s.Limit(con, in)()
 
-	go copy(con, os.Stdout)
-	go copy(os.Stdin, con)
+	if _, ok := unwrap(con).(*tls.Conn); !ok {
+		if InspectTLS {
+			// TLS is expected, so there is nothing to warn about
+			out = &tlsInspector{WriteCloser: out, s: s}
+		} else {
+			out = &tlsWarner{WriteCloser: out, s: s}
+		}
+	}
+	r := withTimeouts(con)
+	if Telnet {
+		r = newTelnetReader(r, con)
+		w = telnetWriter{w}
+	}
+	go copy(r, s.Counting(s.LineCounting(out, false), s.CountReceived), received)
+	if in != nil {
+		go copy(in, s.Counting(s.LineCounting(w, true), s.CountSent), sent)
+	}
 
-	p := <-c
-	log.Printf("[%s]: Connection has been closed by remote peer, %d bytes has been received\n", con.RemoteAddr(), p.bytes)
-	p = <-c
-	log.Printf("[%s]: Local peer has been stopped, %d bytes has been sent\n", con.RemoteAddr(), p.bytes)
+	stopped, closed := false, false
+	for i := 0; i < directions; i++ {
+		select {
+		case p := <-received:
+			if stopped || p.exited {
+				s.Donef("Connection has been closed, %d bytes has been received\n", p.bytes)
+			} else {
+				s.Donef("Connection has been closed by remote peer, %d bytes has been received\n", p.bytes)
+				s.CheckTruncated(p.bytes)
+			}
+			closed = true
+			if in != nil {
+				in.Close()
+			}
+		case p := <-sent:
+			if p.kept && !closed {
+				s.Donef("Local peer has been stopped, %d bytes has been sent, receiving goes on\n", p.bytes)
+				continue
+			}
+			stopped = true
+			s.Donef("Local peer has been stopped, %d bytes has been sent\n", p.bytes)
+			if CloseAfterSend && !closed {
+				// Connection has been closed by sending goroutine, whatever is still in flight isn't waited for
+				s.Donef("Connection has been closed without waiting for response\n")
+				return
+			}
+		}
+	}
 }
 
-// StartServer starts TCP listener
-func StartServer(proto string, port string) {
-	ln, err := net.Listen(proto, port)
+// StartServer starts TCP listener on addr, it's just port (prepended by colon) to listen on all interfaces
+func StartServer(proto string, addr string) {
+	ln, _ := listen(proto, addr)
+	con, err := ln.Accept()
+	if transport.IsAcceptTimeout(err) {
+		log.Fatalf("No connection has been received within %s\n", transport.AcceptTimeout)
+	}
 	if err != nil {
 		log.Fatalln(err)
 	}
-	log.Println("Listening on", proto+port)
-	con, err := ln.Accept()
+	s, ok := opened(con)
+	if !ok {
+		return
+	}
+	// Greeting isn't accounted as sent data, it's reported on its own
+	if len(Handshake) > 0 {
+		if _, err := con.Write(Handshake); err != nil {
+			s.Errorf("Handshake hasn't been sent: %s\n", err)
+			s.Close()
+			con.Close()
+			return
+		}
+		s.Printf("Handshake of %d bytes has been sent\n", len(Handshake))
+	}
+	TransferStreams(con)
+}
+
+// opened opens session of just accepted connection and performs TLS handshake if needed, connection is closed if it has failed
+func opened(con net.Conn) (*transport.Session, bool) {
+	s := transport.Open(con)
+	s.Printf("Connection has been opened\n")
+	if tc, ok := con.(*tls.Conn); ok {
+		if err := handshake(tc); err != nil {
+			s.Errorf("TLS handshake has failed: %s\n", err)
+			s.Close()
+			con.Close()
+			return s, false
+		}
+		LogTLS(s, con)
+	}
+	return s, true
+}
+
+// listen starts listener on addr with Backlog, AcceptTimeout, TCP MD5 signature and TLS applied.
+// Raw TCP listener is returned as well, so accept deadline can be reset.
+func listen(proto string, addr string) (net.Listener, *net.TCPListener) {
+	var lc net.ListenConfig
+	if TCPMD5 != "" {
+		lc.Control = md5Control(net.ParseIP(MD5Peer))
+	}
+	ln, err := lc.Listen(context.Background(), proto, addr)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	log.Printf("[%s]: Connection has been opened\n", con.RemoteAddr())
-	TransferStreams(con)
+	raw := ln.(*net.TCPListener)
+	if Backlog > 0 {
+		if err := setBacklog(ln, Backlog); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if transport.AcceptTimeout > 0 {
+		raw.SetDeadline(time.Now().Add(transport.AcceptTimeout))
+	}
+	if TLS {
+		if ln, err = serverTLS(ln); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	log.Println("Listening on", proto, ln.Addr())
+	return ln, raw
 }
 
 // StartClient starts TCP connector
 func StartClient(proto string, host string, port string) {
-	con, err := net.Dial(proto, host+port)
+	started := time.Now()
+	con, err := Dial(proto, host, port)
+	addr := transport.Address(host, port)
 	if err != nil {
-		log.Fatalln(err)
+		log.Fatalln(dialError(addr, err))
 	}
-	log.Println("Connected to", host+port)
+	if RetryOnReset {
+		con = newResetConn(con, proto, host, port)
+	}
+	s := transport.Dialed(con, started)
+	s.Printf("Connected to %s\n", addr)
+	LogTLS(s, con)
 	TransferStreams(con)
 }
+
+// Dial connects to remote host within Timeout, connection goes through SSH server if tunnel is enabled
+// and is signed by TCP MD5 signature otherwise if it's enabled.
+// PROXY protocol header is sent and TLS handshake is done as well if they are enabled.
+// Refused connection is retried up to Retry times if WaitForListener is set, other errors are returned at once.
+func Dial(proto string, host string, port string) (net.Conn, error) {
+	for attempt := 1; ; attempt++ {
+		con, err := dial(proto, host, port)
+		if err == nil || !WaitForListener || attempt > Retry || !refused(err) {
+			return con, err
+		}
+		log.Printf("Connection to %s has been refused, retry %d of %d in %s\n", transport.Address(host, port), attempt, Retry, RetryInterval)
+		time.Sleep(RetryInterval)
+	}
+}
+
+func dial(proto string, host string, port string) (net.Conn, error) {
+	ip, err := transport.Filter(host)
+	if err != nil {
+		return nil, err
+	}
+	addr := transport.Address(ip, port)
+	var path []string
+	if ConnectVia != "" {
+		// The first hop is dialed, it's told the rest of path
+		path = append(hops(), addr)
+		addr, path = path[0], path[1:]
+	}
+	var con net.Conn
+	if tunnel.Server != "" {
+		con, err = tunnel.Dial(proto, addr, Timeout)
+	} else if SourcePortRange != "" {
+		con, err = dialFromRange(proto, addr)
+	} else {
+		d := dialer()
+		con, err = d.Dial(proto, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(path) > 0 {
+		if err := writeChainHeader(con, path); err != nil {
+			con.Close()
+			return nil, err
+		}
+	}
+	// Header goes before TLS handshake, it describes this very connection
+	if ProxyProtocolV2 {
+		if err := writeProxyHeader(con, con.LocalAddr(), con.RemoteAddr()); err != nil {
+			con.Close()
+			return nil, err
+		}
+	}
+	if !TLS {
+		return con, nil
+	}
+	return clientTLS(con, host)
+}
+
+// dialError turns the most common dial failures into concise messages with a hint, other errors are returned as is
+func dialError(addr string, err error) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("Unable to resolve %s: %s (check host name and DNS settings)", dnsErr.Name, dnsErr.Err)
+	case refused(err):
+		return fmt.Sprintf("Connection to %s has been refused (is anything listening on this port?)", addr)
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return fmt.Sprintf("No route to %s (check network connectivity, routes and firewall)", addr)
+	case errors.As(err, &opErr) && opErr.Timeout():
+		return fmt.Sprintf("Connection to %s has timed out (host is down or packets are filtered)", addr)
+	}
+	return err.Error()
+}
+
+// tlsWarner logs a hint if the first received chunk of plaintext connection looks like TLS record, everything is written as is anyway
+type tlsWarner struct {
+	io.WriteCloser
+	s    *transport.Session
+	once sync.Once
+}
+
+func (t *tlsWarner) Write(b []byte) (int, error) {
+	t.once.Do(func() {
+		if looksLikeTLS(b) {
+			t.s.Printf("WARNING: Received data looks like TLS record, remote peer seems to expect TLS connection (try -tls)\n")
+		}
+	})
+	return t.WriteCloser.Write(b)
+}
+
+// looksLikeTLS checks for TLS record header: handshake (client or server hello) or alert content type followed by version 3.x
+func looksLikeTLS(b []byte) bool {
+	return len(b) >= 3 && (b[0] == 0x16 || b[0] == 0x15) && b[1] == 0x03 && b[2] <= 0x04
+}
+
+// burstWriter keeps Nagle's algorithm enabled for bulk writes but flushes the first write of every burst immediately
+type burstWriter struct {
+	con  *net.TCPConn
+	gap  time.Duration
+	last time.Time
+}
+
+func newBurstWriter(con *net.TCPConn, gap time.Duration) *burstWriter {
+	con.SetNoDelay(false)
+	return &burstWriter{con: con, gap: gap}
+}
+
+func (w *burstWriter) Write(b []byte) (int, error) {
+	now := time.Now()
+	first := now.Sub(w.last) >= w.gap
+	w.last = now
+	if !first {
+		return w.con.Write(b)
+	}
+	// Disabling Nagle's algorithm pushes out anything still pending, so the first write goes out at once
+	w.con.SetNoDelay(true)
+	defer w.con.SetNoDelay(false)
+	return w.con.Write(b)
+}
+
+func (w *burstWriter) Close() error {
+	return w.con.Close()
+}
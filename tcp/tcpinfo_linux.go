@@ -0,0 +1,32 @@
+//go:build linux
+
+package tcp
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpInfo reads kernel statistics of connection (TCP_INFO) and renders the most relevant ones
+func tcpInfo(tc *net.TCPConn) (string, error) {
+	rc, err := tc.SyscallConn()
+	if err != nil {
+		return "", err
+	}
+	var info *unix.TCPInfo
+	var ierr error
+	if err := rc.Control(func(fd uintptr) {
+		info, ierr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil {
+		return "", err
+	}
+	if ierr != nil {
+		return "", ierr
+	}
+	return fmt.Sprintf("rtt %s (variance %s), retransmits %d, lost %d, cwnd %d, ssthresh %d, mss %d, unacked %d",
+		time.Duration(info.Rtt)*time.Microsecond, time.Duration(info.Rttvar)*time.Microsecond,
+		info.Total_retrans, info.Lost, info.Snd_cwnd, info.Snd_ssthresh, info.Snd_mss, info.Unacked), nil
+}
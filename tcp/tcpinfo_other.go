@@ -0,0 +1,13 @@
+//go:build !linux
+
+package tcp
+
+import (
+	"errors"
+	"net"
+)
+
+// tcpInfo is not available, TCP_INFO is Linux-specific
+func tcpInfo(tc *net.TCPConn) (string, error) {
+	return "", errors.New("TCP info is supported on Linux only")
+}
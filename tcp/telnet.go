@@ -0,0 +1,141 @@
+package tcp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Telnet makes connection to be treated as Telnet one: IAC sequences are stripped from received data,
+// option negotiation is answered according to TelnetPolicy and IAC byte of sent data is escaped
+var Telnet bool
+
+// TelnetPolicy is a reply to option negotiation: "refuse" answers WONT to DO and DONT to WILL, "ignore" doesn't answer at all
+var TelnetPolicy = "refuse"
+
+// Telnet commands, see RFC 854
+const (
+	iac  = 255
+	dont = 254
+	do   = 253
+	wont = 252
+	will = 251
+	sb   = 250
+	se   = 240
+)
+
+// CheckTelnet validates Telnet settings
+func CheckTelnet() error {
+	if TelnetPolicy != "refuse" && TelnetPolicy != "ignore" {
+		return fmt.Errorf("Unknown Telnet policy %q", TelnetPolicy)
+	}
+	return nil
+}
+
+type telnetState int
+
+const (
+	telnetData telnetState = iota
+	telnetIAC
+	telnetOption
+	telnetSub
+	telnetSubIAC
+)
+
+// telnetReader strips IAC sequences from received data and answers negotiation, state is kept across reads
+// so sequences split between reads are handled too
+type telnetReader struct {
+	io.ReadCloser
+	reply   io.Writer
+	state   telnetState
+	command byte
+}
+
+func newTelnetReader(r io.ReadCloser, reply io.Writer) *telnetReader {
+	return &telnetReader{ReadCloser: r, reply: reply}
+}
+
+func (t *telnetReader) Read(b []byte) (int, error) {
+	for {
+		n, err := t.ReadCloser.Read(b)
+		n = t.strip(b[:n])
+		// Chunk consisting of negotiation only isn't returned as empty read
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// strip removes IAC sequences from b in place and returns the length of remaining data
+func (t *telnetReader) strip(b []byte) int {
+	n := 0
+	for _, c := range b {
+		switch t.state {
+		case telnetData:
+			if c == iac {
+				t.state = telnetIAC
+				continue
+			}
+			b[n] = c
+			n++
+		case telnetIAC:
+			switch c {
+			case iac:
+				// Escaped data byte
+				b[n] = c
+				n++
+				t.state = telnetData
+			case do, dont, will, wont:
+				t.command = c
+				t.state = telnetOption
+			case sb:
+				t.state = telnetSub
+			default:
+				// Two-byte command (NOP, GA and so on)
+				t.state = telnetData
+			}
+		case telnetOption:
+			t.answer(t.command, c)
+			t.state = telnetData
+		case telnetSub:
+			if c == iac {
+				t.state = telnetSubIAC
+			}
+		case telnetSubIAC:
+			if c == se {
+				t.state = telnetData
+			} else {
+				t.state = telnetSub
+			}
+		}
+	}
+	return n
+}
+
+// answer refuses requested option, DONT and WONT aren't answered as nothing is enabled, so negotiation can't loop
+func (t *telnetReader) answer(command byte, option byte) {
+	if TelnetPolicy != "refuse" {
+		return
+	}
+	switch command {
+	case do:
+		t.reply.Write([]byte{iac, wont, option})
+	case will:
+		t.reply.Write([]byte{iac, dont, option})
+	}
+}
+
+// telnetWriter escapes IAC byte of sent data
+type telnetWriter struct {
+	io.WriteCloser
+}
+
+func (t telnetWriter) Write(b []byte) (int, error) {
+	if bytes.IndexByte(b, iac) < 0 {
+		return t.WriteCloser.Write(b)
+	}
+	if _, err := t.WriteCloser.Write(bytes.ReplaceAll(b, []byte{iac}, []byte{iac, iac})); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,65 @@
+package tcp
+
+import (
+	"io"
+	"testing"
+
+	"github.com/dddpaul/gonc/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelnetReader(t *testing.T) {
+	defer func() { TelnetPolicy = "refuse" }()
+	tests := []struct {
+		name   string
+		policy string
+		in     string
+		out    string
+		reply  string
+	}{
+		{"plain data", "refuse", "login: ", "login: ", ""},
+		{"escaped IAC", "refuse", "a\xff\xffb", "a\xffb", ""},
+		{"DO is refused", "refuse", "\xff\xfd\x18login: ", "login: ", "\xff\xfc\x18"},
+		{"WILL is refused", "refuse", "\xff\xfb\x01\xff\xfb\x03x", "x", "\xff\xfe\x01\xff\xfe\x03"},
+		{"DONT and WONT aren't answered", "refuse", "\xff\xfe\x01\xff\xfc\x01x", "x", ""},
+		{"ignore policy", "ignore", "\xff\xfd\x18\xff\xfb\x01x", "x", ""},
+		{"subnegotiation", "refuse", "a\xff\xfa\x18\x01\xff\xf0b", "ab", ""},
+		{"IAC inside subnegotiation", "refuse", "\xff\xfa\x18\xff\xff\x01\xff\xf0b", "b", ""},
+		{"two-byte command", "refuse", "a\xff\xf1b\xff\xf9c", "abc", ""},
+		{"binary", "refuse", "\x00\xfe\xfd\x80\xff\xff\x01", "\x00\xfe\xfd\x80\xff\x01", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			TelnetPolicy = tt.policy
+			// Every chunk size splits sequences at every possible position
+			for size := 1; size <= len(tt.in); size++ {
+				reply := transport.NewMemory(nil)
+				out, err := io.ReadAll(newTelnetReader(transport.NewMemory(nil, transport.Chunks(tt.in, size)...), reply))
+				assert.NoError(t, err)
+				assert.Equal(t, tt.out, string(out), "chunk size %d", size)
+				assert.Equal(t, tt.reply, reply.Written.String(), "chunk size %d", size)
+			}
+		})
+	}
+}
+
+func TestTelnetWriter(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{"plain data", "hello\r\n", "hello\r\n"},
+		{"IAC is escaped", "a\xffb\xff", "a\xff\xffb\xff\xff"},
+		{"binary", "\x00\xfe\xff\x80", "\x00\xfe\xff\xff\x80"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := transport.NewMemory(nil)
+			n, err := telnetWriter{w}.Write([]byte(tt.in))
+			assert.NoError(t, err)
+			assert.Equal(t, len(tt.in), n)
+			assert.Equal(t, tt.out, w.Written.String())
+		})
+	}
+}
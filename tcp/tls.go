@@ -0,0 +1,163 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dddpaul/gonc/transport"
+)
+
+// TLS enables TLS over TCP connection, TLSCert and TLSKey are required in listen mode
+var TLS bool
+
+// TLSVerifyName is a name server certificate is verified against instead of dialed host, it's sent as SNI as well
+var TLSVerifyName string
+
+// TLSInsecure disables verification of server certificate
+var TLSInsecure bool
+
+// TLSCert is a certificate file (PEM) presented to clients in listen mode
+var TLSCert string
+
+// TLSKey is a private key file (PEM) of TLSCert
+var TLSKey string
+
+// TLSMinVersion and TLSMaxVersion clamp negotiated TLS version, i.e. 1.2, empty means Go default
+var TLSMinVersion, TLSMaxVersion string
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var minVersion, maxVersion uint16
+
+// TLSCipherSuites is a comma-separated list of cipher suite names to offer or accept, empty means Go default.
+// TLS 1.3 suites can't be restricted.
+var TLSCipherSuites string
+
+var cipherSuites []uint16
+
+// CheckTLS validates TLS settings
+func CheckTLS() error {
+	for _, v := range []struct {
+		name    string
+		version *uint16
+	}{{TLSMinVersion, &minVersion}, {TLSMaxVersion, &maxVersion}} {
+		if v.name == "" {
+			continue
+		}
+		version, ok := tlsVersions[v.name]
+		if !ok {
+			return fmt.Errorf("Unknown TLS version %q, valid ones are 1.0, 1.1, 1.2 and 1.3", v.name)
+		}
+		*v.version = version
+	}
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		return fmt.Errorf("Minimum TLS version %s is greater than maximum one %s", TLSMinVersion, TLSMaxVersion)
+	}
+	if err := checkPins(); err != nil {
+		return err
+	}
+	if TLSCipherSuites == "" {
+		return nil
+	}
+	suites := make(map[string]*tls.CipherSuite)
+	var valid []string
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		// TLS 1.3 suites are always enabled
+		if len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13 {
+			continue
+		}
+		suites[suite.Name] = suite
+		valid = append(valid, suite.Name)
+	}
+	for _, name := range strings.Split(TLSCipherSuites, ",") {
+		suite, ok := suites[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("Unknown cipher suite %q, valid ones are (TLS 1.3 suites can't be restricted):\n%s", name, strings.Join(valid, "\n"))
+		}
+		cipherSuites = append(cipherSuites, suite.ID)
+	}
+	return nil
+}
+
+// config returns TLS settings common for client and server
+func config() *tls.Config {
+	return &tls.Config{MinVersion: minVersion, MaxVersion: maxVersion, CipherSuites: cipherSuites}
+}
+
+// clientTLS performs TLS handshake over just dialed connection within Timeout
+func clientTLS(con net.Conn, host string) (net.Conn, error) {
+	name := TLSVerifyName
+	if name == "" {
+		name = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	c := config()
+	c.ServerName, c.InsecureSkipVerify = name, TLSInsecure
+	// Pin replaces CA trust
+	if pinned() {
+		c.InsecureSkipVerify, c.VerifyConnection = true, verifyPins
+	}
+	tc := tls.Client(con, c)
+	if err := handshake(tc); err != nil {
+		con.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// serverTLS wraps listener, so every accepted connection is TLS one
+func serverTLS(ln net.Listener) (net.Listener, error) {
+	if TLSCert == "" || TLSKey == "" {
+		return nil, errors.New("TLS listener requires -tls-cert and -tls-key")
+	}
+	cert, err := tls.LoadX509KeyPair(TLSCert, TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	c := config()
+	c.Certificates = []tls.Certificate{cert}
+	return tls.NewListener(ln, c), nil
+}
+
+func handshake(tc *tls.Conn) error {
+	if Timeout > 0 {
+		tc.SetDeadline(time.Now().Add(Timeout))
+		defer tc.SetDeadline(time.Time{})
+	}
+	return handshakeError(tc.Handshake())
+}
+
+// handshakeError adds a hint to version mismatch reported by either side, other errors are returned as is
+func handshakeError(err error) error {
+	if err != nil && (strings.Contains(err.Error(), "protocol version") || strings.Contains(err.Error(), "unsupported versions")) {
+		return fmt.Errorf("%s (TLS versions of peers don't overlap, check -min-tls and -max-tls)", err)
+	}
+	return err
+}
+
+// LogTLS logs negotiated parameters and names presented by server certificate
+func LogTLS(s *transport.Session, con net.Conn) {
+	tc, ok := unwrap(con).(*tls.Conn)
+	if !ok {
+		return
+	}
+	state := tc.ConnectionState()
+	s.Printf("Negotiated %s with %s cipher suite\n", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite))
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+	leaf := state.PeerCertificates[0]
+	sans := append([]string{}, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	s.Printf("Server certificate: subject %q, SANs %s\n", leaf.Subject.CommonName, strings.Join(sans, ", "))
+}
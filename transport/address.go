@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// Interface is a zone of IPv6 link-local addresses given without one, i.e. eth0
+var Interface string
+
+// AddressFilter is a network (CIDR) address of remote host is picked from if host resolves to several addresses, i.e. 10.1.0.0/16
+var AddressFilter string
+
+var addressFilter *net.IPNet
+
+// CheckAddressFilter parses AddressFilter
+func CheckAddressFilter() error {
+	if AddressFilter == "" {
+		return nil
+	}
+	_, n, err := net.ParseCIDR(AddressFilter)
+	if err != nil {
+		return fmt.Errorf("Invalid address filter: %s", err)
+	}
+	addressFilter = n
+	return nil
+}
+
+// Filter resolves host and returns the first of its addresses within AddressFilter, host is returned as is if there is no filter
+func Filter(host string) (string, error) {
+	if addressFilter == nil {
+		return host, nil
+	}
+	ips, err := net.LookupIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"))
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range ips {
+		if addressFilter.Contains(ip) {
+			log.Printf("Address %s (of %d resolved for %s) has been chosen by filter %s\n", ip, len(ips), host, AddressFilter)
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("None of %d addresses of %s is within %s", len(ips), host, AddressFilter)
+}
+
+// Address joins host and port (prepended by colon) into dial address, so IPv6 hosts are bracketed and keep their zone.
+// Link-local IPv6 host without zone gets Interface as a zone.
+func Address(host string, port string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if Interface != "" && !strings.Contains(host, "%") {
+		if ip := net.ParseIP(host); ip != nil && ip.To4() == nil && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()) {
+			host += "%" + Interface
+		}
+	}
+	return net.JoinHostPort(host, strings.TrimPrefix(port, ":"))
+}
+
+// CheckLocal verifies that host is an address of this machine (or resolves to one), unspecified address is fine as well
+func CheckLocal(host string) error {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return err
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if ip.IsUnspecified() {
+			return nil
+		}
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s isn't an address of this machine", host)
+}
@@ -0,0 +1,84 @@
+package transport
+
+import "io"
+
+// ReadSize limits number of bytes every read (and so every write) moves to force fragmentation, zero means no limit
+var ReadSize int
+
+// Limited caps limit by ReadSize
+func Limited(limit int) int {
+	if ReadSize > 0 && ReadSize < limit {
+		return ReadSize
+	}
+	return limit
+}
+
+// AdaptiveBuffer makes stream copy to start with small buffer which grows with observed read sizes
+var AdaptiveBuffer bool
+
+const (
+	// MinBuffer is an initial size of adaptive buffer
+	MinBuffer = 512
+	// shrinkAfter is a number of sustained small reads after which adaptive buffer is halved
+	shrinkAfter = 16
+)
+
+// Buffer is a read buffer which doubles when reads fill it and halves after sustained small reads
+type Buffer struct {
+	b     []byte
+	limit int
+	small int
+}
+
+// NewBuffer creates adaptive buffer which never grows beyond limit
+func NewBuffer(limit int) *Buffer {
+	return &Buffer{b: make([]byte, min(MinBuffer, limit)), limit: limit}
+}
+
+// Bytes returns buffer to read into
+func (b *Buffer) Bytes() []byte {
+	return b.b
+}
+
+// Adapt resizes buffer according to size of the last read
+func (b *Buffer) Adapt(n int) {
+	size := len(b.b)
+	switch {
+	case n == size && size < b.limit:
+		b.b = make([]byte, min(size*2, b.limit))
+		b.small = 0
+	case n < size/4 && size > MinBuffer:
+		if b.small++; b.small >= shrinkAfter {
+			b.b = make([]byte, size/2)
+			b.small = 0
+		}
+	default:
+		b.small = 0
+	}
+}
+
+// Copy is io.Copy with adaptive buffer of at most limit bytes
+func Copy(w io.Writer, r io.Reader, limit int) (int64, error) {
+	buf := NewBuffer(limit)
+	var written int64
+	for {
+		n, err := r.Read(buf.Bytes())
+		if n > 0 {
+			m, werr := w.Write(buf.Bytes()[0:n])
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+			if m < n {
+				return written, io.ErrShortWrite
+			}
+			buf.Adapt(n)
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
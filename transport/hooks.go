@@ -0,0 +1,52 @@
+package transport
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+)
+
+// OnConnect is a shell command run in background once connection has been opened
+var OnConnect string
+
+// OnClose is a shell command run in background once connection has been closed
+var OnClose string
+
+var hooks sync.WaitGroup
+
+// WaitHooks waits for hooks still running, so they aren't killed by exit
+func WaitHooks() {
+	hooks.Wait()
+}
+
+// hook runs command by shell with session described by GONC_* environment variables, failure is logged only
+func (s *Session) hook(name string, command string) {
+	if command == "" {
+		return
+	}
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+	addr := ""
+	if s.Addr != nil {
+		addr = s.Addr.String()
+	}
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("GONC_CONN_ID=%d", s.ID),
+		"GONC_REMOTE_ADDR="+addr,
+		fmt.Sprintf("GONC_BYTES_RECEIVED=%d", s.Received()),
+		fmt.Sprintf("GONC_BYTES_SENT=%d", s.Sent()),
+	)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	hooks.Add(1)
+	go func() {
+		defer hooks.Done()
+		if err := cmd.Run(); err != nil {
+			s.Errorf("%s hook has failed: %s\n", name, err)
+		}
+	}()
+}
@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"net"
+)
+
+// Memory is in-memory connection, it's used to verify transfer functions without sockets.
+// Read returns Chunks one by one (partially if buffer is too small) followed by Err or io.EOF,
+// everything written is kept in Written.
+type Memory struct {
+	Chunks  [][]byte
+	Err     error
+	Written bytes.Buffer
+}
+
+// NewMemory creates in-memory connection which returns chunks on Read followed by err
+func NewMemory(err error, chunks ...string) *Memory {
+	m := &Memory{Err: err}
+	for _, c := range chunks {
+		m.Chunks = append(m.Chunks, []byte(c))
+	}
+	return m
+}
+
+// Chunks splits s into chunks of given size, the last one may be shorter.
+// Tests feed data chunk by chunk of every size to verify parsers keeping state across writes.
+func Chunks(s string, size int) []string {
+	var chunks []string
+	for i := 0; i < len(s); i += size {
+		chunks = append(chunks, s[i:min(i+size, len(s))])
+	}
+	return chunks
+}
+
+func (m *Memory) Read(b []byte) (int, error) {
+	if len(m.Chunks) == 0 {
+		if m.Err == nil {
+			return 0, io.EOF
+		}
+		return 0, m.Err
+	}
+	n := copy(b, m.Chunks[0])
+	if n < len(m.Chunks[0]) {
+		m.Chunks[0] = m.Chunks[0][n:]
+	} else {
+		m.Chunks = m.Chunks[1:]
+	}
+	return n, nil
+}
+
+func (m *Memory) Write(b []byte) (int, error) {
+	return m.Written.Write(b)
+}
+
+// Close does nothing, data written after close is still kept
+func (m *Memory) Close() error {
+	return nil
+}
+
+// RemoteAddr returns fake address, so connection is always treated as connected one
+func (m *Memory) RemoteAddr() net.Addr {
+	return memoryAddr{}
+}
+
+type memoryAddr struct{}
+
+func (memoryAddr) Network() string { return "memory" }
+func (memoryAddr) String() string  { return "memory" }
@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Progress makes session to render live progress bar on stderr if it's a terminal
+var Progress bool
+
+// ProgressTotal is a number of bytes to be sent, spinner with byte counters is rendered instead of bar if it's unknown (zero)
+var ProgressTotal int64
+
+const (
+	progressInterval = 200 * time.Millisecond
+	progressWidth    = 30
+)
+
+var spinner = []string{"|", "/", "-", "\\"}
+
+type progress struct {
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// startProgress renders progress until stopProgress is called
+func (s *Session) startProgress() {
+	if !Progress || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return
+	}
+	s.progress = &progress{done: make(chan struct{}), stopped: make(chan struct{})}
+	go func() {
+		defer close(s.progress.stopped)
+		t := time.NewTicker(progressInterval)
+		defer t.Stop()
+		for tick := 0; ; tick++ {
+			select {
+			case <-t.C:
+				s.renderProgress(tick)
+			case <-s.progress.done:
+				// Final state is kept on its own line
+				s.renderProgress(tick)
+				fmt.Fprintln(os.Stderr)
+				return
+			}
+		}
+	}()
+}
+
+func (s *Session) stopProgress() {
+	if s.progress == nil {
+		return
+	}
+	close(s.progress.done)
+	<-s.progress.stopped
+}
+
+func (s *Session) renderProgress(tick int) {
+	elapsed := time.Since(s.Started)
+	sent := s.Sent()
+	rate := float64(sent) / elapsed.Seconds()
+	var line string
+	if ProgressTotal > 0 {
+		done := min(float64(sent)/float64(ProgressTotal), 1)
+		filled := int(done * progressWidth)
+		eta := "n/a"
+		if rate > 0 {
+			remaining := max(ProgressTotal-int64(sent), 0)
+			eta = time.Duration(float64(remaining) / rate * float64(time.Second)).Round(time.Second).String()
+		}
+		line = fmt.Sprintf("[%s%s] %3.0f%% %s ETA %s", strings.Repeat("#", filled), strings.Repeat(".", progressWidth-filled), done*100, megabytes(rate), eta)
+	} else {
+		line = fmt.Sprintf("%s %d bytes sent, %d bytes received, %s", spinner[tick%len(spinner)], sent, s.Received(), megabytes(rate))
+	}
+	// Line is padded, so leftovers of longer previous one are erased
+	fmt.Fprintf(os.Stderr, "\r[%s]: %-70s", s, line)
+}
+
+func megabytes(rate float64) string {
+	return fmt.Sprintf("%.2f MB/s", rate/1e6)
+}
@@ -0,0 +1,283 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dddpaul/gonc/metrics"
+)
+
+// Timing makes session to log connection establishment, first received byte and total transfer times once it's closed
+var Timing bool
+
+// MaxDuration limits duration of every session regardless of activity, zero means no limit
+var MaxDuration time.Duration
+
+// Deadline is a wall-clock time every session is closed at regardless of activity, zero means no deadline
+var Deadline time.Time
+
+// QuietEOF silences normal end of transfer, errors are still logged and make exit status non-zero
+var QuietEOF bool
+
+var errorCount uint64
+
+// Failed reports whether any session error has been logged
+func Failed() bool {
+	return atomic.LoadUint64(&errorCount) > 0
+}
+
+// ExpectBytes is a number of bytes remote peer is expected to send before it ends transfer, zero means no expectation
+var ExpectBytes uint64
+
+// StrictEOF makes transfer ended by remote peer before ExpectBytes have been received an error, it's a warning otherwise
+var StrictEOF bool
+
+// CountLines makes session to log number of lines received and sent once it's closed, final unterminated line is counted too
+var CountLines bool
+
+// KeepOnEOF makes connection to keep receiving once local input is over instead of being closed
+var KeepOnEOF bool
+
+// Session describes connection being transferred, sequential ID is assigned at accept/dial time
+type Session struct {
+	ID      uint64
+	Addr    net.Addr
+	Started time.Time
+	// ConnectTime is a time spent to dial remote peer, it's zero for accepted connections
+	ConnectTime time.Duration
+	con         Conn
+	received    uint64
+	sent        uint64
+	firstByte   int64
+	progress    *progress
+	lines       lineCount
+}
+
+// lineCount accounts lines of both directions, partial is set while the last line of direction is unterminated
+type lineCount struct {
+	sync.Mutex
+	received, sent               uint64
+	receivedPartial, sentPartial bool
+}
+
+var lastID uint64
+
+var sessions = struct {
+	sync.Mutex
+	m map[Conn]*Session
+}{m: make(map[Conn]*Session)}
+
+// Open assigns next ID to just accepted or dialed connection
+func Open(con Conn) *Session {
+	s := &Session{ID: atomic.AddUint64(&lastID, 1), Addr: con.RemoteAddr(), Started: time.Now(), con: con}
+	sessions.Lock()
+	sessions.m[con] = s
+	sessions.Unlock()
+	metrics.Connections.Inc()
+	metrics.Active.Inc()
+	s.hook("On-connect", OnConnect)
+	s.startProgress()
+	return s
+}
+
+// Dialed opens just dialed connection, dialing has been started at given time
+func Dialed(con Conn, started time.Time) *Session {
+	s := Open(con)
+	s.ConnectTime = s.Started.Sub(started)
+	return s
+}
+
+// Lookup returns session of connection, connection is opened if it hasn't been yet
+func Lookup(con Conn) *Session {
+	sessions.Lock()
+	s, ok := sessions.m[con]
+	sessions.Unlock()
+	if !ok {
+		s = Open(con)
+	}
+	return s
+}
+
+// Close forgets session
+func (s *Session) Close() {
+	sessions.Lock()
+	delete(sessions.m, s.con)
+	sessions.Unlock()
+	metrics.Active.Dec()
+	s.stopProgress()
+	if Timing {
+		s.logTiming()
+	}
+	if CountLines {
+		s.logLines()
+	}
+	s.hook("On-close", OnClose)
+}
+
+func (s *Session) logTiming() {
+	connect := "n/a"
+	if s.ConnectTime > 0 {
+		connect = s.ConnectTime.String()
+	}
+	firstByte := "n/a"
+	if d := atomic.LoadInt64(&s.firstByte); d > 0 {
+		firstByte = time.Duration(d).String()
+	}
+	s.Printf("Timing: connect %s, first byte %s, total %s\n", connect, firstByte, time.Since(s.Started))
+}
+
+func (s *Session) logLines() {
+	s.lines.Lock()
+	defer s.lines.Unlock()
+	received, sent := s.lines.received, s.lines.sent
+	if s.lines.receivedPartial {
+		received++
+	}
+	if s.lines.sentPartial {
+		sent++
+	}
+	s.Printf("Lines: received %d, sent %d\n", received, sent)
+}
+
+// AccountLines accounts lines of chunk received from (or sent to) remote peer if CountLines is set
+func (s *Session) AccountLines(b []byte, sent bool) {
+	if !CountLines || len(b) == 0 {
+		return
+	}
+	n := uint64(bytes.Count(b, []byte("\n")))
+	partial := b[len(b)-1] != '\n'
+	s.lines.Lock()
+	defer s.lines.Unlock()
+	if sent {
+		s.lines.sent += n
+		s.lines.sentPartial = partial
+	} else {
+		s.lines.received += n
+		s.lines.receivedPartial = partial
+	}
+}
+
+// LineCounting accounts lines written to w by AccountLines, w is returned as is unless CountLines is set
+func (s *Session) LineCounting(w io.WriteCloser, sent bool) io.WriteCloser {
+	if !CountLines {
+		return w
+	}
+	return &countingWriter{WriteCloser: w, count: func(int) {}, lines: func(b []byte) { s.AccountLines(b, sent) }}
+}
+
+// CountReceived accounts bytes received from remote peer
+func (s *Session) CountReceived(n int) {
+	if atomic.LoadInt64(&s.firstByte) == 0 {
+		atomic.CompareAndSwapInt64(&s.firstByte, 0, int64(time.Since(s.Started)))
+	}
+	atomic.AddUint64(&s.received, uint64(n))
+	atomic.AddUint64(&totalReceived, uint64(n))
+	metrics.Bytes.WithLabelValues("received").Add(float64(n))
+}
+
+// CountSent accounts bytes sent to remote peer
+func (s *Session) CountSent(n int) {
+	atomic.AddUint64(&s.sent, uint64(n))
+	atomic.AddUint64(&totalSent, uint64(n))
+	metrics.Bytes.WithLabelValues("sent").Add(float64(n))
+}
+
+// Received returns number of bytes received from remote peer so far
+func (s *Session) Received() uint64 {
+	return atomic.LoadUint64(&s.received)
+}
+
+// Sent returns number of bytes sent to remote peer so far
+func (s *Session) Sent() uint64 {
+	return atomic.LoadUint64(&s.sent)
+}
+
+// Counting accounts every byte written to w by count
+func (s *Session) Counting(w io.WriteCloser, count func(int)) io.WriteCloser {
+	return &countingWriter{WriteCloser: w, count: count}
+}
+
+// Limit closes everything given (nils are skipped) once MaxDuration has passed or Deadline has come, returned function cancels it
+func (s *Session) Limit(closers ...io.Closer) (stop func()) {
+	deadline, reason := Deadline, fmt.Sprintf("Deadline of %s has come", Deadline.Format(time.RFC3339))
+	if MaxDuration > 0 && (deadline.IsZero() || s.Started.Add(MaxDuration).Before(deadline)) {
+		deadline, reason = s.Started.Add(MaxDuration), fmt.Sprintf("Max duration of %s has been reached", MaxDuration)
+	}
+	if deadline.IsZero() {
+		return func() {}
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	go func() {
+		<-ctx.Done()
+		if ctx.Err() != context.DeadlineExceeded {
+			return
+		}
+		s.Printf("%s\n", reason)
+		for _, c := range closers {
+			if c != nil {
+				c.Close()
+			}
+		}
+	}()
+	return cancel
+}
+
+// Printf logs message prefixed by connection ID and remote address
+func (s *Session) Printf(format string, v ...interface{}) {
+	log.Printf("[%s]: %s", s, fmt.Sprintf(format, v...))
+}
+
+// CheckTruncated logs transfer ended by remote peer after received bytes if it's less than ExpectBytes
+func (s *Session) CheckTruncated(received uint64) {
+	if received >= ExpectBytes {
+		return
+	}
+	if StrictEOF {
+		s.Errorf("Truncated transfer, %d of %d expected bytes has been received\n", received, ExpectBytes)
+	} else {
+		s.Printf("WARNING: Truncated transfer, %d of %d expected bytes has been received\n", received, ExpectBytes)
+	}
+}
+
+// Donef logs normal end of transfer direction unless QuietEOF is set
+func (s *Session) Donef(format string, v ...interface{}) {
+	if !QuietEOF {
+		s.Printf(format, v...)
+	}
+}
+
+// Errorf logs error message and accounts it
+func (s *Session) Errorf(format string, v ...interface{}) {
+	atomic.AddUint64(&errorCount, 1)
+	metrics.Errors.Inc()
+	s.Printf("ERROR: "+format, v...)
+}
+
+func (s *Session) String() string {
+	if s.Addr == nil {
+		return fmt.Sprintf("conn#%d", s.ID)
+	}
+	return fmt.Sprintf("conn#%d %s", s.ID, s.Addr)
+}
+
+type countingWriter struct {
+	io.WriteCloser
+	count func(int)
+	lines func([]byte)
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.WriteCloser.Write(b)
+	c.count(n)
+	if c.lines != nil {
+		c.lines(b[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// started is a time the process has been started at, uptime is counted from it
+var started = time.Now()
+
+// totalReceived and totalSent count bytes of all sessions including closed ones
+var totalReceived, totalSent uint64
+
+// previous is the state of the last snapshot, throughput is counted since it
+var previous = struct {
+	sync.Mutex
+	at             time.Time
+	received, sent uint64
+}{at: started}
+
+// WatchSnapshots logs snapshot of active sessions every time SIGUSR1 is received (Unix only), transfers aren't interrupted
+func WatchSnapshots() {
+	c := make(chan os.Signal, 1)
+	if !notifySnapshot(c) {
+		return
+	}
+	go func() {
+		for range c {
+			LogSnapshot()
+		}
+	}()
+}
+
+// LogSnapshot logs number of active sessions, uptime and aggregate throughput since the previous snapshot,
+// then bytes and age of every active session
+func LogSnapshot() {
+	sessions.Lock()
+	active := make([]*Session, 0, len(sessions.m))
+	for _, s := range sessions.m {
+		active = append(active, s)
+	}
+	sessions.Unlock()
+	sort.Slice(active, func(i, j int) bool { return active[i].ID < active[j].ID })
+
+	now := time.Now()
+	received, sent := atomic.LoadUint64(&totalReceived), atomic.LoadUint64(&totalSent)
+	previous.Lock()
+	elapsed := now.Sub(previous.at).Seconds()
+	receivedRate, sentRate := float64(received-previous.received)/elapsed, float64(sent-previous.sent)/elapsed
+	previous.at, previous.received, previous.sent = now, received, sent
+	previous.Unlock()
+
+	log.Printf("Snapshot: %d active connections, uptime %s, throughput received %s, sent %s\n",
+		len(active), now.Sub(started).Round(time.Second), megabytes(receivedRate), megabytes(sentRate))
+	for _, s := range active {
+		s.Printf("Snapshot: %d bytes has been received, %d bytes has been sent, open for %s\n",
+			s.Received(), s.Sent(), now.Sub(s.Started).Round(time.Second))
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package transport
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func notifySnapshot(c chan<- os.Signal) bool {
+	signal.Notify(c, syscall.SIGUSR1)
+	return true
+}
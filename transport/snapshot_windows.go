@@ -0,0 +1,8 @@
+package transport
+
+import "os"
+
+// notifySnapshot does nothing, there is no SIGUSR1 on Windows
+func notifySnapshot(c chan<- os.Signal) bool {
+	return false
+}
@@ -0,0 +1,23 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// AcceptTimeout limits waiting for the first connection (or datagram) in listen mode, zero means no limit
+var AcceptTimeout time.Duration
+
+// IsAcceptTimeout checks whether err is caused by AcceptTimeout
+func IsAcceptTimeout(err error) bool {
+	return AcceptTimeout > 0 && errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// Conn is the subset of net.Conn used by transfer functions
+type Conn interface {
+	io.ReadWriteCloser
+	RemoteAddr() net.Addr
+}
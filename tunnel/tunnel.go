@@ -0,0 +1,129 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Server is SSH server to connect through, i.e. user@bastion:22, tunnel is disabled if empty
+var Server string
+
+// Key is a path to private key file, SSH agent from SSH_AUTH_SOCK is used as well
+var Key string
+
+// KnownHosts is a path to known_hosts file used to verify server host key
+var KnownHosts = filepath.Join(home(), ".ssh", "known_hosts")
+
+// Insecure turns off server host key verification
+var Insecure bool
+
+// conn is a connection forwarded through SSH server, SSH client is closed together with it
+type conn struct {
+	net.Conn
+	client *ssh.Client
+}
+
+func (c *conn) Close() error {
+	err := c.Conn.Close()
+	c.client.Close()
+	return err
+}
+
+// Dial connects to SSH server within timeout and opens forwarded (direct-tcpip) connection to addr through it
+func Dial(proto string, addr string, timeout time.Duration) (net.Conn, error) {
+	name, server := split(Server)
+	auth, err := methods()
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User:            name,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+		Timeout:         timeout,
+	}
+	client, err := ssh.Dial("tcp", server, config)
+	if err != nil {
+		return nil, fmt.Errorf("SSH connection to %s has failed: %w", server, err)
+	}
+	con, err := client.Dial(proto, addr)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("SSH server %s can't connect to %s: %w", server, addr, err)
+	}
+	return &conn{Conn: con, client: client}, nil
+}
+
+// split parses [user@]host[:port] into user name and SSH server address, current user and port 22 are defaults
+func split(s string) (string, string) {
+	name := ""
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		name, s = s[:i], s[i+1:]
+	}
+	if name == "" {
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+	}
+	if _, _, err := net.SplitHostPort(s); err != nil {
+		s = net.JoinHostPort(s, "22")
+	}
+	return name, s
+}
+
+// methods returns public key authentication backed by SSH agent and key file (if any)
+func methods() ([]ssh.AuthMethod, error) {
+	var signers []ssh.Signer
+	if Key != "" {
+		b, err := os.ReadFile(Key)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse %s (add passphrase protected keys to SSH agent instead): %w", Key, err)
+		}
+		signers = append(signers, signer)
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if c, err := net.Dial("unix", sock); err == nil {
+			if s, err := agent.NewClient(c).Signers(); err == nil {
+				signers = append(signers, s...)
+			}
+		}
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("No SSH keys are available, use -ssh-key or SSH agent")
+	}
+	return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, nil
+}
+
+func hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if Insecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	cb, err := knownhosts.New(KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read known hosts (use -ssh-insecure to skip host key verification): %w", err)
+	}
+	return cb, nil
+}
+
+func home() string {
+	dir, _ := os.UserHomeDir()
+	return dir
+}
@@ -0,0 +1,63 @@
+package udp
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// DropRate is a fraction of datagrams dropped on purpose to simulate packet loss
+var DropRate float64
+
+// DupRate is a fraction of datagrams forwarded twice
+var DupRate float64
+
+// DelayJitter is an upper bound of random delay added before every datagram is forwarded
+var DelayJitter time.Duration
+
+// ImpairDirection defines datagrams being impaired: "send", "receive" or "both"
+var ImpairDirection = "both"
+
+func checkImpairment() error {
+	switch ImpairDirection {
+	case "send", "receive", "both":
+	default:
+		return fmt.Errorf("Unknown impairment direction %q", ImpairDirection)
+	}
+	if DropRate < 0 || DropRate > 1 || DupRate < 0 || DupRate > 1 {
+		return errors.New("Drop and duplication rates must be between 0 and 1")
+	}
+	if DelayJitter < 0 {
+		return fmt.Errorf("Delay jitter %s is negative", DelayJitter)
+	}
+	return nil
+}
+
+// impair returns how many times datagram must be forwarded (zero means it's dropped), it waits for random delay if asked
+func impair(sending bool) int {
+	if ImpairDirection == "send" && !sending || ImpairDirection == "receive" && sending {
+		return 1
+	}
+	if DropRate > 0 && rand.Float64() < DropRate {
+		return 0
+	}
+	if DelayJitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(DelayJitter))))
+	}
+	if DupRate > 0 && rand.Float64() < DupRate {
+		return 2
+	}
+	return 1
+}
+
+// impaired applies impairment to every chunk of datagram
+func impaired(chunks [][]byte, sending bool) [][]byte {
+	var result [][]byte
+	for _, chunk := range chunks {
+		for i := impair(sending); i > 0; i-- {
+			result = append(result, chunk)
+		}
+	}
+	return result
+}
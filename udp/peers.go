@@ -0,0 +1,122 @@
+package udp
+
+import (
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dddpaul/gonc/stdio"
+	"github.com/dddpaul/gonc/transport"
+)
+
+// PerPeer makes listener to serve every remote address in its own session with its own local side
+var PerPeer bool
+
+// peerQueue is a number of datagrams waiting for peer session, the rest is dropped
+const peerQueue = 64
+
+// servePeers demultiplexes datagrams of listening connection by remote address, every new address starts new session
+func servePeers(con *net.UDPConn) {
+	// Standard output (along with its tee and transcript files) is set up once and shared by all the sessions,
+	// command and reflector are started for every one of them
+	var shared *stdio.SharedWriter
+	if stdio.Mode == "stdio" && stdio.Exec == "" {
+		_, out, err := stdio.Streams(con.LocalAddr(), nil)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		shared = stdio.NewSharedWriter(out)
+	}
+	peers := make(map[string]*peerConn)
+	var mu sync.Mutex
+	buf := make([]byte, BufferLimit)
+	for {
+		n, addr, err := con.ReadFrom(buf)
+		if transport.IsAcceptTimeout(err) {
+			log.Fatalf("No datagram has been received within %s\n", transport.AcceptTimeout)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		mu.Lock()
+		if transport.AcceptTimeout > 0 && len(peers) == 0 {
+			// The first peer has come, waiting is over
+			con.SetReadDeadline(time.Time{})
+		}
+		p, ok := peers[addr.String()]
+		if !ok {
+			p = &peerConn{con: con, addr: addr, datagrams: make(chan []byte, peerQueue), done: make(chan struct{})}
+			p.forget = func() {
+				mu.Lock()
+				delete(peers, addr.String())
+				mu.Unlock()
+			}
+			peers[addr.String()] = p
+		}
+		mu.Unlock()
+		if !ok {
+			transport.Open(p).Printf("Datagram from new peer has been received\n")
+			if shared == nil {
+				go TransferPackets(p)
+			} else {
+				var in io.ReadCloser
+				if Payload != nil {
+					in = newPayloadReader(Payload)
+				}
+				go Transfer(p, in, shared)
+			}
+		}
+		select {
+		case p.datagrams <- append([]byte(nil), buf[0:n]...):
+		default:
+			log.Printf("Datagram from %s has been dropped, session is too slow\n", addr)
+		}
+	}
+}
+
+// peerConn is a connection to single peer of listening connection, every Read returns single datagram
+type peerConn struct {
+	con       *net.UDPConn
+	addr      net.Addr
+	datagrams chan []byte
+	done      chan struct{}
+	once      sync.Once
+	forget    func()
+}
+
+func (p *peerConn) Read(b []byte) (int, error) {
+	select {
+	case d := <-p.datagrams:
+		return copy(b, d), nil
+	case <-p.done:
+		return 0, net.ErrClosed
+	}
+}
+
+func (p *peerConn) Write(b []byte) (int, error) {
+	select {
+	case <-p.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	return p.con.WriteTo(b, p.addr)
+}
+
+// Close ends peer session, the next datagram from the same address starts new one
+func (p *peerConn) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+		p.forget()
+	})
+	return nil
+}
+
+func (p *peerConn) RemoteAddr() net.Addr {
+	return p.addr
+}
+
+func (p *peerConn) LocalAddr() net.Addr {
+	return p.con.LocalAddr()
+}
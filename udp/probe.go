@@ -0,0 +1,56 @@
+package udp
+
+import (
+	"errors"
+	"log"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/dddpaul/gonc/transport"
+)
+
+// DefaultProbeTimeout is a time to wait for response to every probe if timeout isn't given
+const DefaultProbeTimeout = time.Second
+
+// Probe sends Payload (empty datagram if it isn't set) up to 1+retries times and waits for response to every one within timeout.
+// Port is reported as open if anything has come back, closed if ICMP port unreachable has come back
+// and open|filtered if nothing has come back, which is indistinguishable from filtering. It returns whether port is open.
+func Probe(proto string, host string, port string, timeout time.Duration, retries int) bool {
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	started := time.Now()
+	con, err := Dial(proto, host, port)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer con.Close()
+	s := transport.Dialed(con, started)
+	defer s.Close()
+	buf := make([]byte, BufferLimit)
+	for attempt := 1; attempt <= 1+retries; attempt++ {
+		n, err := con.Write(Payload)
+		if err == nil {
+			s.CountSent(n)
+			con.SetReadDeadline(time.Now().Add(timeout))
+			n, err = con.Read(buf)
+		}
+		switch {
+		case err == nil:
+			s.CountReceived(n)
+			s.Printf("Port is open, %d bytes have been received in response to probe %d\n", n, attempt)
+			return true
+		case errors.Is(err, syscall.ECONNREFUSED):
+			s.Printf("Port is closed, ICMP port unreachable has been received in response to probe %d\n", attempt)
+			return false
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			s.Printf("No response to probe %d within %s\n", attempt, timeout)
+		default:
+			s.Errorf("%s\n", icmpError(err))
+			return false
+		}
+	}
+	s.Printf("Port is open|filtered, nothing has come back\n")
+	return false
+}
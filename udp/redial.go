@@ -0,0 +1,113 @@
+package udp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dddpaul/gonc/transport"
+)
+
+// ReconnectOnIdle is an idle period after which remote host is resolved again before the next datagram is sent,
+// connection is rebuilt if address has changed (i.e. DNS-based failover), zero disables it
+var ReconnectOnIdle time.Duration
+
+// redialConn is client connection which follows changes of remote host address
+type redialConn struct {
+	proto, host, port string
+	mu                sync.Mutex
+	con               *net.UDPConn
+	closed            bool
+	// last is a time of the last successful send or receive, or of the last resolution
+	last int64
+}
+
+func newRedialConn(con *net.UDPConn, proto string, host string, port string) *redialConn {
+	return &redialConn{proto: proto, host: host, port: port, con: con, last: time.Now().UnixNano()}
+}
+
+func (c *redialConn) current() *net.UDPConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.con
+}
+
+func (c *redialConn) touch() {
+	atomic.StoreInt64(&c.last, time.Now().UnixNano())
+}
+
+// Read goes on with the new connection if the old one has been closed because of address change
+func (c *redialConn) Read(b []byte) (int, error) {
+	for {
+		con := c.current()
+		n, err := con.Read(b)
+		if err == nil {
+			c.touch()
+			return n, nil
+		}
+		c.mu.Lock()
+		replaced := con != c.con && !c.closed
+		c.mu.Unlock()
+		if !replaced {
+			return n, err
+		}
+	}
+}
+
+func (c *redialConn) Write(b []byte) (int, error) {
+	if time.Since(time.Unix(0, atomic.LoadInt64(&c.last))) >= ReconnectOnIdle {
+		c.redial()
+	}
+	n, err := c.current().Write(b)
+	if err == nil {
+		c.touch()
+	}
+	return n, err
+}
+
+// redial resolves remote host and replaces connection if address has changed, resolution failure keeps the old one
+func (c *redialConn) redial() {
+	c.touch()
+	s := transport.Lookup(c)
+	addr, err := resolve(c.proto, c.host, c.port)
+	if err != nil {
+		s.Printf("WARNING: Unable to resolve %s again, previous address is kept: %s\n", c.host, err)
+		return
+	}
+	old := c.current()
+	if addr.String() == old.RemoteAddr().String() {
+		return
+	}
+	con, err := net.DialUDP(c.proto, nil, addr)
+	if err != nil {
+		s.Printf("WARNING: Unable to connect to %s, previous address is kept: %s\n", addr, err)
+		return
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		con.Close()
+		return
+	}
+	c.con = con
+	c.mu.Unlock()
+	old.Close()
+	s.Addr = addr
+	s.Printf("Target address has changed from %s to %s\n", old.RemoteAddr(), addr)
+}
+
+func (c *redialConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.con.Close()
+}
+
+func (c *redialConn) RemoteAddr() net.Addr {
+	return c.current().RemoteAddr()
+}
+
+func (c *redialConn) LocalAddr() net.Addr {
+	return c.current().LocalAddr()
+}
@@ -1,10 +1,19 @@
 package udp
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
-	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dddpaul/gonc/stdio"
+	"github.com/dddpaul/gonc/transport"
 )
 
 const (
@@ -14,29 +23,101 @@ const (
 	DisconnectSequence = "~."
 )
 
+// LockPeer makes listener to ignore datagrams from anyone except the first peer
+var LockPeer bool
+
+// Payload is sent as the first datagram in client mode instead of standard input, responses are printed until session is over
+var Payload []byte
+
+// DisconnectMatch defines how datagram is compared to DisconnectSequence: "exact" requires it to be the whole datagram,
+// "trimmed" ignores trailing line ending and whitespace (i.e. "~.\r\n")
+var DisconnectMatch = "trimmed"
+
+// StrictPeer makes listener to warn once about every source other than the first peer, its datagrams are still received
+var StrictPeer bool
+
+// MaxDatagramSize limits size of datagrams sent to remote peer, zero means no limit (up to BufferLimit)
+var MaxDatagramSize int
+
+// SplitDatagrams makes input exceeding MaxDatagramSize to be sent as several datagrams instead of being rejected
+var SplitDatagrams bool
+
+// ForwardEmpty makes zero-length datagrams to be forwarded instead of being skipped
+var ForwardEmpty bool
+
+// ProbeInterval is an idle period after which keepalive datagram is sent to remote peer (i.e. to keep NAT mapping), zero disables it
+var ProbeInterval time.Duration
+
+// ProbePayload is sent as keepalive datagram, it's not accounted as sent data
+var ProbePayload string
+
 // Progress indicates transfer status
 type Progress struct {
 	remoteAddr net.Addr
 	bytes      uint64
+	// kept is set if connection has been left open after local input is over
+	kept bool
+}
+
+// Check validates UDP settings
+func Check() error {
+	if DisconnectMatch != "exact" && DisconnectMatch != "trimmed" {
+		return fmt.Errorf("Unknown disconnect match %q", DisconnectMatch)
+	}
+	if isDisconnect([]byte(ProbePayload)) {
+		return errors.New("Keepalive payload would disconnect remote peer")
+	}
+	if PerPeer && stdio.Mode == "stdio" && stdio.Exec == "" && !stdio.NoStdin {
+		return errors.New("Session per peer requires local side other than standard input: -exec, -mode reflect or -no-stdin")
+	}
+	if PerPeer && (stdio.Exec != "" || stdio.Mode != "stdio") && (stdio.Tee != "" || stdio.TeeSend != "" || stdio.Transcript != "") {
+		return errors.New("Tee and transcript files can't be shared by sessions per peer with their own command or reflector")
+	}
+	if PerPeer && stdio.Tail > 0 {
+		return errors.New("Tail can't be combined with session per peer, shared output is never closed")
+	}
+	return checkImpairment()
 }
 
 // TransferPackets launches receive goroutine first, wait for address from it (if needed), launches send goroutine then
-func TransferPackets(con net.Conn) {
-	c := make(chan Progress)
+func TransferPackets(con transport.Conn) {
+	var local net.Addr
+	if l, ok := con.(interface{ LocalAddr() net.Addr }); ok {
+		local = l.LocalAddr()
+	}
+	in, out, err := stdio.Streams(local, con.RemoteAddr())
+	if err != nil {
+		s := transport.Lookup(con)
+		s.Errorf("%s\n", err)
+		s.Close()
+		con.Close()
+		return
+	}
+	if Payload != nil && con.RemoteAddr() != nil {
+		in = newPayloadReader(Payload)
+	}
+	Transfer(con, in, out)
+}
 
-	// Read from Reader and write to Writer until EOF.
-	// ra is an address to whom packets must be sent in listen mode.
-	copy := func(r io.ReadCloser, w io.WriteCloser, ra net.Addr) {
-		defer func() {
-			r.Close()
-			w.Close()
-		}()
+// Transfer is TransferPackets with local side given by in and out instead of local side from stdio, nothing is sent if in is nil
+func Transfer(con transport.Conn, in io.ReadCloser, out io.WriteCloser) {
+	s := transport.Lookup(con)
+	defer s.Close()
+	received := make(chan Progress)
+	sent := make(chan Progress)
+	var lastSent int64
+	impairing := DropRate > 0 || DupRate > 0 || DelayJitter > 0
 
+	// Read from Reader and write to Writer until EOF or disconnect sequence.
+	// ra is an address to whom packets must be sent in listen mode.
+	copy := func(r io.ReadCloser, w io.WriteCloser, ra net.Addr, c chan Progress) {
 		buf := make([]byte, BufferLimit)
 		bytes := uint64(0)
 		var n int
 		var err error
 		var addr net.Addr
+		// Unexpected sources which have been warned about already
+		strangers := make(map[string]bool)
 
 		for {
 			// Read
@@ -44,80 +125,299 @@ func TransferPackets(con net.Conn) {
 				n, addr, err = con.ReadFrom(buf)
 				// In listen mode remote address is unknown until read from connection.
 				// So we must inform caller function with received remote address.
-				if con.RemoteAddr() == nil && ra == nil {
+				if transport.IsAcceptTimeout(err) && ra == nil {
+					log.Fatalf("No datagram has been received within %s\n", transport.AcceptTimeout)
+				}
+				if err == nil && con.RemoteAddr() == nil && ra == nil {
+					if transport.AcceptTimeout > 0 {
+						con.SetReadDeadline(time.Time{})
+					}
 					ra = addr
+					s.Addr = ra
 					c <- Progress{remoteAddr: ra}
 				}
+				if LockPeer && err == nil && con.RemoteAddr() == nil && addr.String() != ra.String() {
+					s.Printf("Datagram from %s has been dropped\n", addr)
+					continue
+				}
+				if StrictPeer && err == nil && con.RemoteAddr() == nil && addr.String() != ra.String() && !strangers[addr.String()] {
+					strangers[addr.String()] = true
+					s.Printf("WARNING: Datagram from %s has been received, responses are still sent to the first peer %s\n", addr, ra)
+				}
+			} else if c == sent {
+				// Local input is read in pieces of ReadSize if asked
+				n, err = r.Read(buf[:transport.Limited(BufferLimit)])
 			} else {
+				// Datagrams are received whole whatever connection (i.e. per peer or redialing one) they come from
 				n, err = r.Read(buf)
 			}
 			if err != nil {
-				if err != io.EOF {
-					log.Printf("[%s]: ERROR: %s\n", ra, err)
+				// Connection is closed deliberately when session is over
+				if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+					s.Errorf("%s\n", icmpError(err))
 				}
 				break
 			}
-			if string(buf[0:n-1]) == DisconnectSequence {
+			if n == 0 {
+				// Zero-length datagrams are legal (some protocols use them as keepalives), they are skipped unless asked otherwise
+				if !ForwardEmpty {
+					continue
+				}
+			} else if isDisconnect(buf[0:n]) {
 				break
 			}
 
 			// Write
-			if con, ok := w.(*net.UDPConn); ok && con.RemoteAddr() == nil {
-				// Connection remote address must be nil otherwise "WriteTo with pre-connected connection" will be thrown
-				n, err = con.WriteTo(buf[0:n], ra)
-			} else {
-				n, err = w.Write(buf[0:n])
+			chunks := [][]byte{buf[0:n]}
+			if w == con && MaxDatagramSize > 0 && n > MaxDatagramSize {
+				if !SplitDatagrams {
+					s.Errorf("%d bytes exceed maximum datagram size of %d bytes\n", n, MaxDatagramSize)
+					break
+				}
+				chunks = split(buf[0:n], MaxDatagramSize)
+			}
+			if impairing {
+				chunks = impaired(chunks, w == con)
+			}
+			for _, chunk := range chunks {
+				n, err = writeTo(w, chunk, ra)
+				if errors.Is(err, stdio.ErrExit) {
+					// Chunk has been written anyway
+					bytes += uint64(n)
+					s.CountReceived(n)
+					s.AccountLines(chunk[:n], false)
+					s.Printf("%s\n", err)
+					break
+				}
+				if err != nil {
+					if errors.Is(err, syscall.EMSGSIZE) {
+						s.Errorf("%d bytes datagram is too long, try to lower -max-datagram-size\n", len(chunk))
+					} else {
+						s.Errorf("%s\n", icmpError(err))
+					}
+					break
+				}
+				bytes += uint64(n)
+				s.AccountLines(chunk[:n], w == con)
+				if w == con {
+					atomic.StoreInt64(&lastSent, time.Now().UnixNano())
+					s.CountSent(n)
+				} else {
+					s.CountReceived(n)
+				}
 			}
 			if err != nil {
-				log.Printf("[%s]: ERROR: %s\n", ra, err)
 				break
 			}
-			bytes += uint64(n)
 		}
-		c <- Progress{bytes: bytes}
+		// Both are closed before progress is reported, so local side (i.e. buffered output) is done when Transfer returns.
+		// Connection is still receiving after local input is over if asked, disconnect sequence closes it anyway.
+		kept := c == sent && err == io.EOF && transport.KeepOnEOF
+		r.Close()
+		if !kept {
+			w.Close()
+		}
+		c <- Progress{bytes: bytes, kept: kept}
 	}
 
+	// Input may be a terminal which can't be unblocked by closing
+	if in != nil {
+		in = stdio.NewCancelReader(in)
+	}
+	defer s.Limit(con, in)()
+
 	ra := con.RemoteAddr()
-	go copy(con, os.Stdout, ra)
+	go copy(con, out, ra, received)
 	// If connection hasn't got remote address then wait for it from receiver goroutine
 	if ra == nil {
-		p := <-c
+		p := <-received
+		if p.remoteAddr == nil {
+			// Receiver has finished before the first datagram
+			s.Donef("Connection has been closed, %d bytes has been received\n", p.bytes)
+			if in != nil {
+				in.Close()
+			}
+			return
+		}
 		ra = p.remoteAddr
-		log.Printf("[%s]: Datagram has been received\n", ra)
+		s.Printf("Datagram has been received\n")
+	}
+	if ProbeInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		atomic.StoreInt64(&lastSent, time.Now().UnixNano())
+		go probe(s, con, ra, &lastSent, done)
+	}
+	// Nothing is sent without input
+	directions := 1
+	if in != nil {
+		directions = 2
+		go copy(in, con, ra, sent)
 	}
-	go copy(os.Stdin, con, ra)
 
-	p := <-c
-	log.Printf("[%s]: Connection has been closed, %d bytes has been received\n", ra, p.bytes)
-	p = <-c
-	log.Printf("[%s]: Local peer has been stopped, %d bytes has been sent\n", ra, p.bytes)
+	// Disconnect sequence from either side ends the whole session: receiver stops sender by closing input,
+	// sender stops receiver by closing connection
+	closed := false
+	for i := 0; i < directions; i++ {
+		select {
+		case p := <-received:
+			s.Donef("Connection has been closed, %d bytes has been received\n", p.bytes)
+			s.CheckTruncated(p.bytes)
+			closed = true
+			if in != nil {
+				in.Close()
+			}
+		case p := <-sent:
+			if p.kept && !closed {
+				s.Donef("Local peer has been stopped, %d bytes has been sent, receiving goes on\n", p.bytes)
+				continue
+			}
+			s.Donef("Local peer has been stopped, %d bytes has been sent\n", p.bytes)
+		}
+	}
 }
 
-// StartServer starts UDP listener
-func StartServer(proto string, port string) {
-	addr, err := net.ResolveUDPAddr(proto, port)
+// StartServer starts UDP listener on addr, it's just port (prepended by colon) to listen on all interfaces
+func StartServer(proto string, addr string) {
+	laddr, err := net.ResolveUDPAddr(proto, addr)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	con, err := net.ListenUDP(proto, addr)
+	con, err := net.ListenUDP(proto, laddr)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	log.Println("Listening on", proto+port)
+	if transport.AcceptTimeout > 0 {
+		con.SetReadDeadline(time.Now().Add(transport.AcceptTimeout))
+	}
+	log.Println("Listening on", proto, con.LocalAddr())
+	if PerPeer {
+		servePeers(con)
+		return
+	}
 	// This connection doesn't know remote address yet
 	TransferPackets(con)
 }
 
 // StartClient starts UDP connector
 func StartClient(proto string, host string, port string) {
-	addr, err := net.ResolveUDPAddr(proto, host+port)
+	started := time.Now()
+	con, err := Dial(proto, host, port)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	con, err := net.DialUDP(proto, nil, addr)
+	var c transport.Conn = con
+	if ReconnectOnIdle > 0 {
+		c = newRedialConn(con, proto, host, port)
+	}
+	transport.Dialed(c, started).Printf("Sending datagrams to %s\n", transport.Address(host, port))
+	TransferPackets(c)
+}
+
+// Dial creates UDP connection bound to remote host, no packets are sent yet
+func Dial(proto string, host string, port string) (*net.UDPConn, error) {
+	addr, err := resolve(proto, host, port)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
-	log.Println("Sending datagrams to", host+port)
-	TransferPackets(con)
+	return net.DialUDP(proto, nil, addr)
+}
+
+// resolve returns UDP address of remote host picked by AddressFilter if it's set
+func resolve(proto string, host string, port string) (*net.UDPAddr, error) {
+	ip, err := transport.Filter(host)
+	if err != nil {
+		return nil, err
+	}
+	return net.ResolveUDPAddr(proto, transport.Address(ip, port))
+}
+
+// probe sends keepalive datagram once nothing has been sent for ProbeInterval until done is closed
+func probe(s *transport.Session, con transport.Conn, ra net.Addr, lastSent *int64, done chan struct{}) {
+	t := time.NewTicker(ProbeInterval / 2)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			if now.Sub(time.Unix(0, atomic.LoadInt64(lastSent))) < ProbeInterval {
+				continue
+			}
+			if _, err := writeTo(con, []byte(ProbePayload), ra); err != nil {
+				// Connection is closed when session is over
+				if !errors.Is(err, net.ErrClosed) {
+					s.Errorf("Keepalive has failed: %s\n", icmpError(err))
+				}
+				return
+			}
+			atomic.StoreInt64(lastSent, now.UnixNano())
+		case <-done:
+			return
+		}
+	}
+}
+
+// icmpError turns ICMP errors reported by kernel on connected socket into clear messages, other errors are returned as is
+func icmpError(err error) error {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errors.New("Destination port unreachable (nothing is listening on remote port)")
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return errors.New("Destination host unreachable (host is down or TTL has expired on the way)")
+	case errors.Is(err, syscall.ENETUNREACH):
+		return errors.New("Destination network unreachable (check routes)")
+	}
+	return err
+}
+
+// isDisconnect checks whether datagram is disconnect sequence according to DisconnectMatch
+func isDisconnect(b []byte) bool {
+	if DisconnectMatch == "exact" {
+		return string(b) == DisconnectSequence
+	}
+	return string(bytes.TrimRight(b, "\r\n \t")) == DisconnectSequence
+}
+
+// writeTo writes datagram to w, it must be addressed explicitly when w is not connected UDP connection
+func writeTo(w io.Writer, b []byte, ra net.Addr) (int, error) {
+	if con, ok := w.(*net.UDPConn); ok && con.RemoteAddr() == nil {
+		// Connection remote address must be nil otherwise "WriteTo with pre-connected connection" will be thrown
+		return con.WriteTo(b, ra)
+	}
+	return w.Write(b)
+}
+
+// split slices b into chunks of at most size bytes
+func split(b []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(b) > size {
+		chunks = append(chunks, b[0:size])
+		b = b[size:]
+	}
+	return append(chunks, b)
+}
+
+// payloadReader returns payload at once and blocks until it's closed then, so responses are received meanwhile
+type payloadReader struct {
+	payload []byte
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newPayloadReader(payload []byte) *payloadReader {
+	return &payloadReader{payload: payload, done: make(chan struct{})}
+}
+
+func (p *payloadReader) Read(b []byte) (int, error) {
+	if len(p.payload) > 0 {
+		n := copy(b, p.payload)
+		p.payload = p.payload[n:]
+		return n, nil
+	}
+	<-p.done
+	return 0, io.EOF
+}
+
+func (p *payloadReader) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
 }
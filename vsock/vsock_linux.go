@@ -0,0 +1,86 @@
+package vsock
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dddpaul/gonc/tcp"
+	"github.com/dddpaul/gonc/transport"
+	"github.com/mdlayher/vsock"
+)
+
+// StartServer starts vsock listener, addr is CID:port or just port (CID of this machine is used then)
+func StartServer(addr string) {
+	cid, port, err := parse(addr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var ln *vsock.Listener
+	if cid == nil {
+		ln, err = vsock.Listen(port, nil)
+	} else {
+		ln, err = vsock.ListenContextID(*cid, port, nil)
+	}
+	if err != nil {
+		log.Fatalln(vsockError(err))
+	}
+	log.Println("Listening on", ln.Addr())
+	con, err := ln.Accept()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	transport.Open(con).Printf("Connection has been opened\n")
+	tcp.TransferStreams(con)
+}
+
+// StartClient starts vsock connector, addr is CID:port
+func StartClient(addr string) {
+	cid, port, err := parse(addr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if cid == nil {
+		log.Fatalf("Context ID is missing in %q, i.e. 3:1024\n", addr)
+	}
+	started := time.Now()
+	con, err := vsock.Dial(*cid, port, nil)
+	if err != nil {
+		log.Fatalln(vsockError(err))
+	}
+	transport.Dialed(con, started).Printf("Connected to %s\n", con.RemoteAddr())
+	tcp.TransferStreams(con)
+}
+
+// parse splits CID:port, CID is nil if addr is just port (optionally prepended by colon)
+func parse(addr string) (*uint32, uint32, error) {
+	c, p := "", strings.TrimPrefix(addr, ":")
+	if i := strings.LastIndex(p, ":"); i >= 0 {
+		c, p = p[:i], p[i+1:]
+	}
+	port, err := strconv.ParseUint(p, 10, 32)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid vsock port in %q, i.e. 3:1024", addr)
+	}
+	if c == "" {
+		return nil, uint32(port), nil
+	}
+	cid, err := strconv.ParseUint(c, 10, 32)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid vsock context ID in %q, i.e. 3:1024", addr)
+	}
+	id := uint32(cid)
+	return &id, uint32(port), nil
+}
+
+// vsockError adds a hint when vsock isn't available on this machine at all
+func vsockError(err error) error {
+	if errors.Is(err, syscall.EAFNOSUPPORT) || errors.Is(err, syscall.ENODEV) || errors.Is(err, syscall.ENOENT) {
+		return fmt.Errorf("%s (vsock device isn't present, is vsock kernel module loaded?)", err)
+	}
+	return err
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package vsock
+
+import "log"
+
+// StartServer is not available, vsock is supported on Linux only
+func StartServer(addr string) {
+	log.Fatalln("vsock is supported on Linux only")
+}
+
+// StartClient is not available, vsock is supported on Linux only
+func StartClient(addr string) {
+	log.Fatalln("vsock is supported on Linux only")
+}